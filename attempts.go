@@ -0,0 +1,92 @@
+package otp
+
+import (
+	"sync"
+	"time"
+)
+
+// Limits verification attempts per key, to thwart brute-forcing of OTPs: at
+// most MaxFailures attempts are allowed within any sliding Window, after which
+// the key is locked out until the oldest of them leaves the window. Attempts
+// are cleared by Reset, which should follow a successful verification. It is
+// safe for concurrent use.
+type Attempts struct {
+	MaxFailures int
+	Window      time.Duration
+
+	mu        sync.Mutex
+	attempts  map[string][]time.Time
+	lastSweep time.Time
+	// Overridable for testing.
+	now func() time.Time
+}
+
+// Returns an Attempts that allows maxFailures failed attempts per window.
+func NewAttempts(maxFailures int, window time.Duration) *Attempts {
+	return &Attempts{MaxFailures: maxFailures, Window: window}
+}
+
+// Reports whether a verification attempt for keyID may proceed, and if so,
+// records it. Until Reset is called, every allowed attempt counts as a failure.
+func (a *Attempts) Allow(keyID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	if a.now != nil {
+		now = a.now()
+	}
+	if a.attempts == nil {
+		a.attempts = make(map[string][]time.Time)
+	}
+	// Sweeping at most once per Window bounds the map without scanning it on
+	// every call.
+	if now.Sub(a.lastSweep) >= a.Window {
+		for id := range a.attempts {
+			a.prune(id, now)
+		}
+		a.lastSweep = now
+	}
+	if len(a.prune(keyID, now)) >= a.MaxFailures {
+		return false
+	}
+	a.attempts[keyID] = append(a.attempts[keyID], now)
+	return true
+}
+
+// Forgets the attempts for keyID, ending any lockout.
+func (a *Attempts) Reset(keyID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.attempts, keyID)
+}
+
+// Drops the attempts for keyID that have left the window, returning those
+// remaining.
+func (a *Attempts) prune(keyID string, now time.Time) []time.Time {
+	ts := a.attempts[keyID]
+	i := 0
+	for i < len(ts) && now.Sub(ts[i]) >= a.Window {
+		i++
+	}
+	ts = ts[i:]
+	if len(ts) == 0 {
+		delete(a.attempts, keyID)
+		return nil
+	}
+	a.attempts[keyID] = ts
+	return ts
+}
+
+// Like Verify, but first consults a, rejecting code without checking it if
+// keyID is locked out. A successful verification resets the attempts.
+func (k *TOTPKey) VerifyWithAttempts(keyID, code string, skew uint,
+	a *Attempts) bool {
+	if !a.Allow(keyID) {
+		return false
+	}
+	if !k.Verify(code, skew) {
+		return false
+	}
+	a.Reset(keyID)
+	return true
+}
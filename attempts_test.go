@@ -0,0 +1,67 @@
+package otp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttempts(t *testing.T) {
+	now := time.Unix(1000, 0)
+	a := NewAttempts(3, time.Minute)
+	a.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !a.Allow("alice") {
+			t.Fatalf("Failure: attempt %d rejected", i+1)
+		}
+		now = now.Add(10 * time.Second)
+	}
+	if a.Allow("alice") {
+		t.Errorf("Failure: attempt past MaxFailures allowed")
+	}
+	if !a.Allow("bob") {
+		t.Errorf("Failure: lockout of one key affected another")
+	}
+
+	// The window slides: the first attempt, at 1000, expires at 1060.
+	now = time.Unix(1059, 0)
+	if a.Allow("alice") {
+		t.Errorf("Failure: attempt allowed before the window slid")
+	}
+	now = time.Unix(1060, 0)
+	if !a.Allow("alice") {
+		t.Errorf("Failure: attempt rejected after the window slid")
+	}
+	if a.Allow("alice") {
+		t.Errorf("Failure: two attempts allowed for one expired")
+	}
+
+	a.Reset("alice")
+	if !a.Allow("alice") {
+		t.Errorf("Failure: attempt rejected after Reset")
+	}
+
+	now = now.Add(10 * time.Minute)
+	a.Allow("carol")
+	if len(a.attempts) != 1 {
+		t.Errorf("Failure: %d entries remain after expiry, want 1", len(a.attempts))
+	}
+}
+
+func TestVerifyWithAttempts(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	a := NewAttempts(2, time.Minute)
+	code := k.OTP()
+	if k.VerifyWithAttempts("alice", "", 1, a) {
+		t.Errorf("Failure: malformed code accepted")
+	}
+	if !k.VerifyWithAttempts("alice", code, 1, a) {
+		t.Errorf("Failure: correct code rejected")
+	}
+	// The success reset the attempts, so two more failures are allowed.
+	k.VerifyWithAttempts("alice", "", 1, a)
+	k.VerifyWithAttempts("alice", "", 1, a)
+	if k.VerifyWithAttempts("alice", code, 1, a) {
+		t.Errorf("Failure: correct code accepted during lockout")
+	}
+}
@@ -0,0 +1,211 @@
+package otp
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The version of the binary encoding of keys, which is their leading byte, so
+// that later versions can add fields while earlier ones remain decodable.
+// Version 2 added ClockOffset to TOTPKeys, and version 3 Domain to both.
+const binaryVersion = 3
+
+// The second byte of the binary encoding of keys, which tells them apart.
+const (
+	binaryHOTP = 'H'
+	binaryTOTP = 'T'
+)
+
+// Marks a dynamic TruncationOffset in the binary encoding of HOTPKeys.
+const binaryDynamic = 0xFF
+
+var ErrMalformedBinary = errors.New("otp: malformed binary key")
+
+var (
+	_ encoding.BinaryMarshaler   = (*HOTPKey)(nil)
+	_ encoding.BinaryUnmarshaler = (*HOTPKey)(nil)
+	_ encoding.BinaryMarshaler   = (*TOTPKey)(nil)
+	_ encoding.BinaryUnmarshaler = (*TOTPKey)(nil)
+)
+
+// Encodes the HOTP parameter-set in a compact binary form, for storage:
+//
+//	version (1 byte), 'H', SecretEncoding, Digits, Encoding,
+//	TruncationOffset (0xFF if dynamic), Counter (8 bytes, big-endian),
+//	HashFunction, SecretKey, and Domain (each a uvarint length and the string)
+//
+// As with JSON, a custom base-32 encoding is not included.
+func (k *HOTPKey) MarshalBinary() ([]byte, error) {
+	offset := byte(binaryDynamic)
+	if k.TruncationOffset != nil {
+		if *k.TruncationOffset < 0 || *k.TruncationOffset > maxTruncationOffset {
+			return nil, ErrOffsetOutOfRange
+		}
+		offset = byte(*k.TruncationOffset)
+	}
+	b := []byte{binaryVersion, binaryHOTP, byte(k.SecretEncoding), k.Digits,
+		byte(k.Encoding), offset}
+	b = appendUint64(b, k.Counter)
+	b = appendString(b, string(k.HashFunction))
+	b = appendString(b, k.SecretKey)
+	return appendString(b, k.Domain), nil
+}
+
+// Decodes an HOTP parameter-set encoded by MarshalBinary, including by earlier
+// versions, and validates it. An invalid parameter-set yields
+// ErrInvalidHOTPKey, and a malformed encoding ErrMalformedBinary. As in
+// UnmarshalJSON, the settings of the receiver's Set methods are kept.
+func (k *HOTPKey) UnmarshalBinary(data []byte) error {
+	d, err := newBinaryDecoder(data, binaryHOTP)
+	if err != nil {
+		return err
+	}
+	var v HOTPKey
+	v.SecretEncoding = SecretEncoding(d.byte())
+	v.Digits = d.byte()
+	v.Encoding = OutputEncoding(d.byte())
+	if o := int(d.byte()); o != binaryDynamic {
+		v.TruncationOffset = &o
+	}
+	v.Counter = d.uint64()
+	v.HashFunction = HashFunction(d.string())
+	v.SecretKey = d.string()
+	if d.version >= 3 {
+		v.Domain = d.string()
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	v.copySettings(k)
+	if !v.Validate() {
+		return ErrInvalidHOTPKey
+	}
+	*k = v
+	return nil
+}
+
+// Encodes the TOTP parameter-set in a compact binary form, for storage:
+//
+//	version (1 byte), 'T', SecretEncoding, Digits,
+//	TimeStep, T0, and ClockOffset in nanoseconds (each 8 bytes, big-endian),
+//	HashFunction, SecretKey, and Domain (each a uvarint length and the string)
+//
+// As with JSON, a custom base-32 encoding is not included.
+func (k *TOTPKey) MarshalBinary() ([]byte, error) {
+	b := []byte{binaryVersion, binaryTOTP, byte(k.SecretEncoding), k.Digits}
+	b = appendUint64(b, k.TimeStep)
+	b = appendUint64(b, k.T0)
+	b = appendUint64(b, uint64(k.ClockOffset))
+	b = appendString(b, string(k.HashFunction))
+	b = appendString(b, k.SecretKey)
+	return appendString(b, k.Domain), nil
+}
+
+// Decodes a TOTP parameter-set encoded by MarshalBinary, including by earlier
+// versions, and validates it. An invalid parameter-set yields
+// ErrInvalidTOTPKey, and a malformed encoding ErrMalformedBinary. As in
+// UnmarshalJSON, the settings of the receiver's Set methods are kept.
+func (k *TOTPKey) UnmarshalBinary(data []byte) error {
+	d, err := newBinaryDecoder(data, binaryTOTP)
+	if err != nil {
+		return err
+	}
+	var v TOTPKey
+	v.SecretEncoding = SecretEncoding(d.byte())
+	v.Digits = d.byte()
+	v.TimeStep = d.uint64()
+	v.T0 = d.uint64()
+	if d.version >= 2 {
+		v.ClockOffset = time.Duration(d.uint64())
+	}
+	v.HashFunction = HashFunction(d.string())
+	v.SecretKey = d.string()
+	if d.version >= 3 {
+		v.Domain = d.string()
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	v.copySettings(k)
+	if !v.Validate() {
+		return ErrInvalidTOTPKey
+	}
+	*k = v
+	return nil
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendString(b []byte, s string) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(s)))
+	return append(append(b, buf[:n]...), s...)
+}
+
+// Reads the fields of a binary-encoded key in turn. Reading past the end of
+// the data sets the error reported by finish, rather than panicking, so that
+// fields can be read without checking each one.
+type binaryDecoder struct {
+	version byte
+	b       []byte
+	err     error
+}
+
+// Returns a decoder for the fields of data following its version and kind,
+// which must be at most binaryVersion and kind.
+func newBinaryDecoder(data []byte, kind byte) (*binaryDecoder, error) {
+	if len(data) < 2 {
+		return nil, ErrMalformedBinary
+	}
+	if data[0] == 0 || data[0] > binaryVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrMalformedBinary, data[0])
+	}
+	if data[1] != kind {
+		return nil, fmt.Errorf("%w: not a %c key", ErrMalformedBinary, kind)
+	}
+	return &binaryDecoder{version: data[0], b: data[2:]}, nil
+}
+
+func (d *binaryDecoder) next(n int) []byte {
+	if d.err != nil || len(d.b) < n {
+		d.err = ErrMalformedBinary
+		return make([]byte, n)
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *binaryDecoder) byte() byte {
+	return d.next(1)[0]
+}
+
+func (d *binaryDecoder) uint64() uint64 {
+	return binary.BigEndian.Uint64(d.next(8))
+}
+
+func (d *binaryDecoder) string() string {
+	n, m := binary.Uvarint(d.b)
+	if d.err != nil || m <= 0 || n > uint64(len(d.b)-m) {
+		d.err = ErrMalformedBinary
+		return ""
+	}
+	d.b = d.b[m:]
+	return string(d.next(int(n)))
+}
+
+// Returns the error of the reads, if any, and ErrMalformedBinary if data
+// remains unread.
+func (d *binaryDecoder) finish() error {
+	if d.err == nil && len(d.b) != 0 {
+		d.err = ErrMalformedBinary
+	}
+	return d.err
+}
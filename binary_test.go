@@ -0,0 +1,151 @@
+package otp
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	offset := maxTruncationOffset
+	hw := []HOTPKey{
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6},
+		{SecretKey: "gezd gnbv gy3t qojq gezd gnbv gy3t qojq", HashFunction: SHA512, Digits: MaxDigits, Counter: math.MaxUint64},
+		{SecretKey: "12345678901234567890", SecretEncoding: EncodingRaw, HashFunction: SHA256, Digits: 8, Counter: 1 << 32, TruncationOffset: &offset, Encoding: Hex},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Domain: "login"},
+	}
+	for _, k := range hw {
+		b, err := k.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failure: key %v: %v", k, err)
+		}
+		var got HOTPKey
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("Failure: key %v: %v", k, err)
+		}
+		if got.StringWithSecret() != k.StringWithSecret() {
+			t.Errorf("Mismatch on round-trip:\nWant: %s Got: %s", k.StringWithSecret(), got.StringWithSecret())
+		}
+		if got.OTP() != k.OTP() {
+			t.Errorf("Mismatch on OTP of key %v:\nWant: %s Got: %s", k, k.OTP(), got.OTP())
+		}
+	}
+
+	tw := []TOTPKey{
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA256, Digits: 8, TimeStep: math.MaxUint64, T0: 1000},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, ClockOffset: -90*time.Second - 1},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, Domain: "signing"},
+	}
+	for _, k := range tw {
+		b, err := k.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failure: key %v: %v", k, err)
+		}
+		var got TOTPKey
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("Failure: key %v: %v", k, err)
+		}
+		if got != k {
+			t.Errorf("Mismatch on round-trip:\nWant: %s Got: %s", k.StringWithSecret(), got.StringWithSecret())
+		}
+	}
+
+	// Six single-byte fields, the counter, and the length-prefixed "SHA1",
+	// secret-key, and empty Domain.
+	if b, _ := hw[0].MarshalBinary(); len(b) != 2+4+8+1+4+1+32+1 {
+		t.Errorf("Failure: binary form is %d bytes", len(b))
+	}
+}
+
+func TestUnmarshalBinaryVersion1(t *testing.T) {
+	// Version 1 encodings, which lack ClockOffset, remain decodable.
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, T0: 7}
+	b := []byte{1, binaryTOTP, 0, 6}
+	b = appendUint64(b, 30)
+	b = appendUint64(b, 7)
+	b = appendString(b, "SHA1")
+	b = appendString(b, tk.SecretKey)
+	var got TOTPKey
+	if err := got.UnmarshalBinary(b); err != nil || got != tk {
+		t.Errorf("Mismatch on version 1:\nWant: %s Got: %s (%v)", tk.StringWithSecret(), got.StringWithSecret(), err)
+	}
+	if err := got.UnmarshalBinary(append([]byte{0}, b[1:]...)); !errors.Is(err, ErrMalformedBinary) {
+		t.Errorf("Failure: version 0: got %v, want %v", err, ErrMalformedBinary)
+	}
+}
+
+func TestUnmarshalBinaryMalformed(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}
+	b, _ := k.MarshalBinary()
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	tb, _ := tk.MarshalBinary()
+
+	var got HOTPKey
+	for i := 0; i < len(b); i++ {
+		if err := got.UnmarshalBinary(b[:i]); !errors.Is(err, ErrMalformedBinary) {
+			t.Errorf("Failure: truncated to %d bytes: got %v, want %v", i, err, ErrMalformedBinary)
+		}
+	}
+	if err := got.UnmarshalBinary(append(b[:len(b):len(b)], 0)); !errors.Is(err, ErrMalformedBinary) {
+		t.Errorf("Failure: trailing data: got %v, want %v", err, ErrMalformedBinary)
+	}
+	if err := got.UnmarshalBinary(append([]byte{binaryVersion + 1}, b[1:]...)); !errors.Is(err, ErrMalformedBinary) {
+		t.Errorf("Failure: unknown version: got %v, want %v", err, ErrMalformedBinary)
+	}
+	if err := got.UnmarshalBinary(tb); !errors.Is(err, ErrMalformedBinary) {
+		t.Errorf("Failure: TOTP key decoded as HOTP: got %v, want %v", err, ErrMalformedBinary)
+	}
+	var gotT TOTPKey
+	if err := gotT.UnmarshalBinary(b); !errors.Is(err, ErrMalformedBinary) {
+		t.Errorf("Failure: HOTP key decoded as TOTP: got %v, want %v", err, ErrMalformedBinary)
+	}
+
+	k.Digits = 0
+	b, _ = k.MarshalBinary()
+	if err := got.UnmarshalBinary(b); err != ErrInvalidHOTPKey {
+		t.Errorf("Failure: invalid key: got %v, want %v", err, ErrInvalidHOTPKey)
+	}
+	tk.TimeStep = 0
+	tb, _ = tk.MarshalBinary()
+	if err := gotT.UnmarshalBinary(tb); err != ErrInvalidTOTPKey {
+		t.Errorf("Failure: invalid key: got %v, want %v", err, ErrInvalidTOTPKey)
+	}
+	if got.SecretKey != "" || gotT.SecretKey != "" {
+		t.Errorf("Failure: receiver modified by a failed decode")
+	}
+	offset := 16
+	k.TruncationOffset = &offset
+	if _, err := k.MarshalBinary(); err != ErrOffsetOutOfRange {
+		t.Errorf("Failure: offset 16: got %v, want %v", err, ErrOffsetOutOfRange)
+	}
+}
+
+func TestUnmarshalBinaryKeepsSettings(t *testing.T) {
+	hk := reversedAlphabetKey()
+	data, err := hk.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	var got HOTPKey
+	got.SetBase32Encoding(reversedBase32)
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if otp := got.OTP(); otp != "94287082" {
+		t.Errorf("Mismatch on custom alphabet\nWant: %s Got: %s", "94287082", otp)
+	}
+
+	tk := TOTPKey{SecretKey: hk.SecretKey, HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	tk.SetBase32Encoding(reversedBase32)
+	data, _ = tk.MarshalBinary()
+	var gotT TOTPKey
+	gotT.SetBase32Encoding(reversedBase32)
+	if err := gotT.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if otp := gotT.OTPAt(time.Unix(59, 0)); otp != "94287082" {
+		t.Errorf("Mismatch on custom alphabet\nWant: %s Got: %s", "94287082", otp)
+	}
+}
@@ -0,0 +1,208 @@
+package otp
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Computes and returns count successive OTPs, starting from the current
+// counter, without modifying the receiver HOTPKey. Fewer are returned if the
+// counter would overflow. If the receiver HOTPKey is invalid, OnInvalidKey
+// is called.
+func (k *HOTPKey) Codes(count int) []string {
+	g, err := k.generator()
+	if err != nil {
+		invalidKey(err)
+		return nil
+	}
+	var res []string
+	for i, ctr := 0, k.Counter; i < count; i, ctr = i+1, ctr+1 {
+		otp, err := g.generate(ctr)
+		if err != nil {
+			invalidKey(err)
+			return nil
+		}
+		res = append(res, otp)
+		if ctr == math.MaxUint64 {
+			break
+		}
+	}
+	return res
+}
+
+// Computes and returns n single-use backup codes, to be printed for when the
+// user's token is unavailable: the OTPs of the n counters from the current
+// one, as Codes returns. The receiver HOTPKey is not modified, so a key whose
+// counter is still in use by a token should instead be given its own
+// secret-key for its scratch codes. Use VerifyScratch to verify them.
+func (k *HOTPKey) ScratchCodes(n int) []string {
+	return k.Codes(n)
+}
+
+// Verifies code against the n scratch codes returned by ScratchCodes, in any
+// order, skipping the counters in used. On a match, the matched counter is
+// returned, which the caller must add to used and persist, so that the code
+// cannot be used again. The comparison is constant-time. If the receiver
+// HOTPKey or code is malformed, false is returned.
+func (k *HOTPKey) VerifyScratch(code string, n int, used []uint64) (bool, uint64) {
+	code = trimCode(code)
+	if len(code) != int(k.Digits) {
+		return false, 0
+	}
+	g, err := k.generator()
+	if err != nil {
+		return false, 0
+	}
+	for i, ctr := 0, k.Counter; i < n; i, ctr = i+1, ctr+1 {
+		if !containsCounter(used, ctr) {
+			otp, err := g.generate(ctr)
+			if err != nil {
+				return false, 0
+			}
+			if Equal(otp, code) {
+				return true, ctr
+			}
+		}
+		if ctr == math.MaxUint64 {
+			break
+		}
+	}
+	return false, 0
+}
+
+func containsCounter(s []uint64, c uint64) bool {
+	for _, v := range s {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes count successive OTPs to w, separated by sep, starting from the
+// current counter and without modifying the receiver HOTPKey. As with Codes,
+// fewer are written if the counter would overflow. Unlike Codes, an invalid
+// key or a failed write is reported as an error.
+func (k *HOTPKey) Stream(w io.Writer, count int, sep string) error {
+	g, err := k.generator()
+	if err != nil {
+		return err
+	}
+	for i, ctr := 0, k.Counter; i < count; i, ctr = i+1, ctr+1 {
+		otp, err := g.generate(ctr)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			otp = sep + otp
+		}
+		if _, err := io.WriteString(w, otp); err != nil {
+			return err
+		}
+		if ctr == math.MaxUint64 {
+			break
+		}
+	}
+	return nil
+}
+
+// Computes and returns the current OTP followed by those of the next count-1
+// time-steps. If the receiver TOTPKey is invalid, OnInvalidKey is called.
+func (k *TOTPKey) Codes(count int) []string {
+	return k.codesAt(time.Now(), count)
+}
+
+func (k *TOTPKey) codesAt(t time.Time, count int) []string {
+	if err := k.ValidateDetailed(); err != nil {
+		invalidKey(err)
+		return nil
+	}
+	h, err := k.conv(t)
+	if err != nil {
+		invalidKey(err)
+		return nil
+	}
+	return h.Codes(count)
+}
+
+// The most codes that CodesBetween returns: about 3 weeks' worth, at the
+// default time-step.
+const MaxCodesBetween = 1 << 16
+
+var ErrRangeTooLarge = fmt.Errorf("otp: time range spans more than %d time-steps", MaxCodesBetween)
+
+// Computes and returns the OTPs of every time-step from the one containing
+// start through the one containing end, such as for auditing which codes a key
+// produced during an incident. No OTPs are returned if end precedes start. An
+// error is returned if the key is invalid, if start precedes T0, or if the
+// range spans more than MaxCodesBetween time-steps.
+func (k *TOTPKey) CodesBetween(start, end time.Time) ([]string, error) {
+	if err := k.ValidateDetailed(); err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, nil
+	}
+	from, err := k.CounterAt(start)
+	if err != nil {
+		return nil, err
+	}
+	to, err := k.CounterAt(end)
+	if err != nil {
+		return nil, err
+	}
+	if to-from >= MaxCodesBetween {
+		return nil, ErrRangeTooLarge
+	}
+	g, err := k.hotp(from).generator()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, to-from+1)
+	for ctr := from; ctr <= to; ctr++ {
+		otp, err := g.generate(ctr)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, otp)
+	}
+	return res, nil
+}
+
+// An OTP paired with the time for which it was computed, as returned by
+// Timeline.
+type TimedCode struct {
+	At   time.Time
+	Code string
+}
+
+// Computes and returns the OTPs for steps consecutive time-steps, the first
+// computed for time start and each following one a TimeStep later, each paired
+// with its time, such as for plotting codes against those of another
+// implementation. Unlike CodesBetween, which returns only the codes, each
+// entry carries its time. steps is clamped to MaxCodesBetween. If the receiver
+// TOTPKey is invalid, or start precedes T0, OnInvalidKey is called.
+func (k *TOTPKey) Timeline(start time.Time, steps int) []TimedCode {
+	if steps <= 0 {
+		return nil
+	}
+	if steps > MaxCodesBetween {
+		steps = MaxCodesBetween
+	}
+	if err := k.ValidateDetailed(); err != nil {
+		invalidKey(err)
+		return nil
+	}
+	res := make([]TimedCode, 0, steps)
+	for i := 0; i < steps; i++ {
+		at := time.Unix(start.Unix()+int64(i)*int64(k.TimeStep), 0)
+		otp := k.OTPAt(at)
+		if otp == "" {
+			return nil
+		}
+		res = append(res, TimedCode{At: at, Code: otp})
+	}
+	return res
+}
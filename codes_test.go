@@ -0,0 +1,206 @@
+package otp
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodes(t *testing.T) {
+	hk := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 3}
+	// RFC 4226 appendix D values for counters 3 through 6.
+	want := []string{"969429", "338314", "254676", "287922"}
+	if got := hk.Codes(4); !reflect.DeepEqual(got, want) {
+		t.Errorf("Mismatch on key %+v:\nWant: %v Got: %v", hk, want, got)
+	}
+	if hk.Counter != 3 {
+		t.Errorf("Failure: Codes mutated the receiver's counter to %d", hk.Counter)
+	}
+	for i, otp := range hk.Codes(4) {
+		h := hk
+		h.Counter += uint64(i)
+		if otp != h.OTP() {
+			t.Errorf("Mismatch with OTP at counter %d:\nWant: %s Got: %s", h.Counter, h.OTP(), otp)
+		}
+	}
+	if got := hk.Codes(0); len(got) != 0 {
+		t.Errorf("Failure: Codes(0) returned %v", got)
+	}
+
+	hk.Counter = math.MaxUint64 - 1
+	if got := hk.Codes(4); len(got) != 2 {
+		t.Errorf("Failure: Codes past counter overflow returned %d codes", len(got))
+	}
+
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	// Counters 3 through 6, from within the step of counter 3.
+	if got := tk.codesAt(time.Unix(100, 0), 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("Mismatch on key %+v:\nWant: %v Got: %v", tk, want, got)
+	}
+	if got := tk.Codes(3); len(got) != 3 {
+		t.Errorf("Failure: Codes(3) returned %d codes", len(got))
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("write failed")
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestScratchCodes(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 0}
+	codes := k.ScratchCodes(10)
+	// RFC 4226 appendix D.
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	if strings.Join(codes, ",") != strings.Join(want, ",") || k.Counter != 0 {
+		t.Fatalf("Mismatch on ScratchCodes:\nWant: %v Got: %v", want, codes)
+	}
+
+	// Codes verify in any order, but each only once.
+	var used []uint64
+	for _, i := range []int{7, 0, 9, 3} {
+		ok, ctr := k.VerifyScratch(codes[i], len(codes), used)
+		if !ok || ctr != uint64(i) {
+			t.Fatalf("Failure: code %d: got (%v, %d)", i, ok, ctr)
+		}
+		used = append(used, ctr)
+		if ok, _ := k.VerifyScratch(codes[i], len(codes), used); ok {
+			t.Errorf("Failure: code %d accepted twice", i)
+		}
+	}
+	if ok, ctr := k.VerifyScratch(codes[1], len(codes), used); !ok || ctr != 1 {
+		t.Errorf("Failure: unused code 1: got (%v, %d)", ok, ctr)
+	}
+	// Codes beyond n, and malformed ones, are rejected.
+	if ok, _ := k.VerifyScratch(codes[9], 9, nil); ok {
+		t.Errorf("Failure: code beyond n accepted")
+	}
+	for _, c := range []string{"", "75522", "7552240", "000000"} {
+		if ok, _ := k.VerifyScratch(c, len(codes), nil); ok {
+			t.Errorf("Failure: code %q accepted", c)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	hk := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 3}
+	var b strings.Builder
+	if err := hk.Stream(&b, 4, "\n"); err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	want := "969429\n338314\n254676\n287922"
+	if b.String() != want {
+		t.Errorf("Mismatch on key %+v:\nWant: %q Got: %q", hk, want, b.String())
+	}
+	if want := strings.Join(hk.Codes(4), "\n"); b.String() != want {
+		t.Errorf("Mismatch with Codes:\nWant: %q Got: %q", want, b.String())
+	}
+	if hk.Counter != 3 {
+		t.Errorf("Failure: Stream mutated the receiver's counter to %d", hk.Counter)
+	}
+
+	b.Reset()
+	if err := hk.Stream(&b, 0, ","); err != nil || b.Len() != 0 {
+		t.Errorf("Failure: Stream of 0 codes wrote %q (%v)", b.String(), err)
+	}
+
+	hk.Counter = math.MaxUint64 - 1
+	b.Reset()
+	if err := hk.Stream(&b, 4, ","); err != nil || strings.Count(b.String(), ",") != 1 {
+		t.Errorf("Failure: Stream past counter overflow wrote %q (%v)", b.String(), err)
+	}
+
+	if err := hk.Stream(&failingWriter{n: 1}, 4, ","); err == nil {
+		t.Errorf("Failure: write error was not surfaced")
+	}
+	bad := HOTPKey{SecretKey: "NOTBASE32!", HashFunction: SHA1, Digits: 6}
+	if err := bad.Stream(&b, 1, ","); !errors.Is(err, ErrBadBase32) {
+		t.Errorf("Failure: invalid key: got error %v, want %v", err, ErrBadBase32)
+	}
+}
+
+func TestCodesBetween(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	// The RFC 6238 appendix B values for times 1111111109 and 1111111111,
+	// which are in adjacent time-steps.
+	got, err := k.CodesBetween(time.Unix(1111111109, 0), time.Unix(1111111111, 0))
+	want := []string{"07081804", "14050471"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Mismatch:\nWant: %v Got: %v (%v)", want, got, err)
+	}
+	// Both bounds are inclusive, and within one time-step yield one code.
+	got, err = k.CodesBetween(time.Unix(1111111110, 0), time.Unix(1111111139, 0))
+	if err != nil || !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("Mismatch within a time-step:\nWant: %v Got: %v (%v)", want[1:], got, err)
+	}
+	got, err = k.CodesBetween(time.Unix(0, 0), time.Unix(30*9+1, 0))
+	if err != nil || len(got) != 10 {
+		t.Fatalf("Failure: 10 time-steps: got %d codes (%v)", len(got), err)
+	}
+	for i, otp := range got {
+		if at := k.OTPAt(time.Unix(int64(30*i), 0)); otp != at {
+			t.Errorf("Mismatch with OTPAt on step %d:\nWant: %s Got: %s", i, at, otp)
+		}
+	}
+
+	if got, err := k.CodesBetween(time.Unix(100, 0), time.Unix(99, 0)); err != nil || len(got) != 0 {
+		t.Errorf("Failure: reversed range: got %v (%v)", got, err)
+	}
+	start := time.Unix(1111111109, 0)
+	if _, err := k.CodesBetween(start, start.Add(MaxCodesBetween*30*time.Second)); err != ErrRangeTooLarge {
+		t.Errorf("Failure: oversized range: got %v, want %v", err, ErrRangeTooLarge)
+	}
+	if got, err := k.CodesBetween(start, start.Add((MaxCodesBetween-1)*30*time.Second)); err != nil || len(got) != MaxCodesBetween {
+		t.Errorf("Failure: largest range: got %d codes (%v)", len(got), err)
+	}
+	k.T0 = 1000
+	if _, err := k.CodesBetween(time.Unix(999, 0), time.Unix(2000, 0)); err != ErrBeforeT0 {
+		t.Errorf("Failure: range before T0: got %v, want %v", err, ErrBeforeT0)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	// From the last second of one time-step, each entry is in the next one:
+	// the RFC 6238 appendix B values for times 1111111109 and 1111111139.
+	got := k.Timeline(time.Unix(1111111109, 0), 2)
+	want := []TimedCode{
+		{time.Unix(1111111109, 0), "07081804"},
+		{time.Unix(1111111139, 0), "14050471"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Mismatch:\nWant: %v Got: %v", want, got)
+	}
+	codes, _ := k.CodesBetween(time.Unix(0, 0), time.Unix(30*9, 0))
+	for i, v := range k.Timeline(time.Unix(0, 0), 10) {
+		if v.Code != codes[i] || v.At.Unix() != int64(30*i) {
+			t.Errorf("Mismatch on step %d:\nWant: %s at %d Got: %s at %d", i, codes[i], 30*i, v.Code, v.At.Unix())
+		}
+	}
+
+	if got := k.Timeline(time.Unix(0, 0), 0); len(got) != 0 {
+		t.Errorf("Failure: zero steps: got %v", got)
+	}
+	if got := k.Timeline(time.Unix(0, 0), MaxCodesBetween+1); len(got) != MaxCodesBetween {
+		t.Errorf("Mismatch on clamped steps\nWant: %d Got: %d", MaxCodesBetween, len(got))
+	}
+
+	defer func(f func(error)) { OnInvalidKey = f }(OnInvalidKey)
+	var errs []error
+	OnInvalidKey = func(err error) { errs = append(errs, err) }
+	k.T0 = 1000
+	if got := k.Timeline(time.Unix(999, 0), 2); got != nil {
+		t.Errorf("Failure: timeline before T0: got %v", got)
+	}
+	if len(errs) != 1 || errs[0] != ErrBeforeT0 {
+		t.Errorf("Failure: hook called with %v", errs)
+	}
+}
@@ -0,0 +1,64 @@
+package otp
+
+import (
+	"errors"
+	"math"
+)
+
+// Specifies the direction in which an HOTP counter moves. Most tokens count up,
+// but some count down.
+type Direction int8
+
+const (
+	Increment Direction = 1
+	Decrement Direction = -1
+)
+
+var (
+	ErrCounterUnderflow = errors.New("otp: counter cannot be decremented below 0")
+	ErrCounterOverflow  = errors.New("otp: counter cannot be incremented past math.MaxUint64")
+	ErrUnknownDirection = errors.New("otp: unknown counter direction")
+)
+
+// Increments the counter. If it is math.MaxUint64, an error is returned and
+// the counter is left as is, rather than wrapping around to 0 and so to codes
+// already used.
+func (k *HOTPKey) Increment() error {
+	if k.Counter == math.MaxUint64 {
+		return ErrCounterOverflow
+	}
+	k.Counter++
+	return nil
+}
+
+// Computes and returns an OTP using the HOTP parameter-set, and then
+// increments the counter. If the counter is math.MaxUint64, an error is
+// returned and nothing is generated, since the counter cannot advance without
+// wrapping around to codes already used.
+func (k *HOTPKey) Next() (string, error) {
+	if k.Counter == math.MaxUint64 {
+		return "", ErrCounterOverflow
+	}
+	otp, err := k.Generate()
+	if err != nil {
+		return "", err
+	}
+	k.Counter++
+	return otp, nil
+}
+
+// Computes and returns an OTP using the HOTP parameter-set, and then
+// decrements the counter, for tokens that count down. If the counter is 0, an
+// error is returned and nothing is generated, since the counter cannot advance
+// without wrapping around to codes already used.
+func (k *HOTPKey) OTPAndDecrement() (string, error) {
+	if k.Counter == 0 {
+		return "", ErrCounterUnderflow
+	}
+	otp, err := k.Generate()
+	if err != nil {
+		return "", err
+	}
+	k.Counter--
+	return otp, nil
+}
@@ -0,0 +1,135 @@
+package otp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOTPAndDecrement(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 2}
+	// RFC 4226 appendix D values for counters 2 and 1.
+	for _, want := range []string{"359152", "287082"} {
+		otp, err := k.OTPAndDecrement()
+		if err != nil || otp != want {
+			t.Errorf("Mismatch:\nWant: %s Got: %s (%v)", want, otp, err)
+		}
+	}
+	if k.Counter != 0 {
+		t.Fatalf("Failure: counter is %d, want 0", k.Counter)
+	}
+	if otp, err := k.OTPAndDecrement(); err != ErrCounterUnderflow || otp != "" {
+		t.Errorf("Failure: at counter 0: got (%q, %v), want (\"\", %v)", otp, err, ErrCounterUnderflow)
+	}
+	if k.Counter != 0 {
+		t.Errorf("Failure: counter wrapped around to %d", k.Counter)
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: math.MaxUint64 - 1}
+	if err := k.Increment(); err != nil || k.Counter != math.MaxUint64 {
+		t.Errorf("Failure: below math.MaxUint64: got counter %d (%v)", k.Counter, err)
+	}
+	if err := k.Increment(); err != ErrCounterOverflow {
+		t.Errorf("Failure: at math.MaxUint64: got %v, want %v", err, ErrCounterOverflow)
+	}
+	if k.Counter != math.MaxUint64 {
+		t.Errorf("Failure: counter wrapped around to %d", k.Counter)
+	}
+}
+
+func TestNext(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}
+	// RFC 4226 appendix D values for counters 0 and 1.
+	for _, want := range []string{"755224", "287082"} {
+		otp, err := k.Next()
+		if err != nil || otp != want {
+			t.Errorf("Mismatch:\nWant: %s Got: %s (%v)", want, otp, err)
+		}
+	}
+	if k.Counter != 2 {
+		t.Errorf("Failure: counter is %d, want 2", k.Counter)
+	}
+
+	k.Counter = math.MaxUint64 - 1
+	want := k.OTP()
+	if otp, err := k.Next(); err != nil || otp != want || k.Counter != math.MaxUint64 {
+		t.Errorf("Failure: below math.MaxUint64: got (%q, %v) and counter %d", otp, err, k.Counter)
+	}
+	if otp, err := k.Next(); err != ErrCounterOverflow || otp != "" {
+		t.Errorf("Failure: at math.MaxUint64: got (%q, %v), want (\"\", %v)", otp, err, ErrCounterOverflow)
+	}
+	if k.Counter != math.MaxUint64 {
+		t.Errorf("Failure: counter wrapped around to %d", k.Counter)
+	}
+
+	bad := HOTPKey{SecretKey: "NOTBASE32!", HashFunction: SHA1, Digits: 6}
+	if _, err := bad.Next(); err == nil || bad.Counter != 0 {
+		t.Errorf("Failure: invalid key: got error %v and counter %d", err, bad.Counter)
+	}
+}
+
+func TestVerifyDirection(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 9}
+	// RFC 4226 appendix D values for counters 0 through 9.
+	want := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+
+	if ok, ctr := k.VerifyDirection(want[7], 3, Decrement); !ok || ctr != 6 {
+		t.Errorf("Failure: counter 7: got (%v, %d), want (true, 6)", ok, ctr)
+	}
+	if ok, ctr := k.VerifyDirection(want[5], 3, Decrement); ok || ctr != 9 {
+		t.Errorf("Failure: code beyond look-ahead window: got (%v, %d), want (false, 9)", ok, ctr)
+	}
+	if ok, _ := k.VerifyDirection(want[7], 3, Increment); ok {
+		t.Errorf("Failure: past counter accepted when incrementing")
+	}
+	if ok, _ := k.VerifyDirection(want[9], 3, 0); ok {
+		t.Errorf("Failure: invalid direction accepted")
+	}
+
+	// Counter 0 has no predecessor to resume from.
+	k.Counter = 1
+	if ok, ctr := k.VerifyDirection(want[1], 3, Decrement); !ok || ctr != 0 {
+		t.Errorf("Failure: counter 1: got (%v, %d), want (true, 0)", ok, ctr)
+	}
+	if ok, _ := k.VerifyDirection(want[0], 3, Decrement); ok {
+		t.Errorf("Failure: counter 0 accepted when decrementing")
+	}
+}
+
+func TestVerifyDirectionDetailed(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 3}
+	// RFC 4226 appendix D values for counters 0 through 3.
+	want := []string{"755224", "287082", "359152", "969429"}
+
+	if ok, ctr, err := k.VerifyDirectionDetailed(want[2], 1, Decrement); !ok || ctr != 1 || err != nil {
+		t.Errorf("Failure: counter 2: got (%v, %d, %v), want (true, 1, nil)", ok, ctr, err)
+	}
+	// Within the range, a wrong code is only a mismatch.
+	if ok, ctr, err := k.VerifyDirectionDetailed("000000", 2, Decrement); ok || ctr != 3 || err != nil {
+		t.Errorf("Failure: wrong code: got (%v, %d, %v), want (false, 3, nil)", ok, ctr, err)
+	}
+	// Reaching counter 0 is reported, whether or not the code was for it.
+	for _, code := range []string{want[0], "000000"} {
+		if ok, ctr, err := k.VerifyDirectionDetailed(code, 3, Decrement); ok || ctr != 3 || err != ErrCounterUnderflow {
+			t.Errorf("Failure: code %s: got (%v, %d, %v), want (false, 3, %v)", code, ok, ctr, err, ErrCounterUnderflow)
+		}
+	}
+	// A match before the end of the range is not an error.
+	if ok, ctr, err := k.VerifyDirectionDetailed(want[1], 3, Decrement); !ok || ctr != 0 || err != nil {
+		t.Errorf("Failure: counter 1: got (%v, %d, %v), want (true, 0, nil)", ok, ctr, err)
+	}
+
+	k.Counter = math.MaxUint64 - 1
+	if ok, _, err := k.VerifyDirectionDetailed("000000", 3, Increment); ok || err != ErrCounterOverflow {
+		t.Errorf("Failure: at the last counters: got (%v, %v), want (false, %v)", ok, err, ErrCounterOverflow)
+	}
+	if _, _, err := k.VerifyDirectionDetailed(want[0], 3, 0); err != ErrUnknownDirection {
+		t.Errorf("Failure: invalid direction: got %v, want %v", err, ErrUnknownDirection)
+	}
+	bad := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1}
+	if _, _, err := bad.VerifyDirectionDetailed(want[0], 3, Increment); err != ErrZeroDigits {
+		t.Errorf("Failure: invalid key: got %v, want %v", err, ErrZeroDigits)
+	}
+}
@@ -0,0 +1,68 @@
+package otp
+
+import (
+	"errors"
+	"sync"
+)
+
+// Returned by MemoryCounterStore.Load for key IDs with no saved counter.
+var ErrNoCounter = errors.New("otp: no counter saved for key")
+
+// Persists HOTP counters by key ID, so that they survive restarts and can be
+// shared by stateless servers.
+type CounterStore interface {
+	Load(keyID string) (uint64, error)
+	Save(keyID string, c uint64) error
+}
+
+// An in-memory CounterStore, safe for concurrent use.
+type MemoryCounterStore struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// Returns the counter saved for keyID, or ErrNoCounter if there is none.
+func (s *MemoryCounterStore) Load(keyID string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.counters[keyID]
+	if !ok {
+		return 0, ErrNoCounter
+	}
+	return c, nil
+}
+
+// Saves c as the counter for keyID.
+func (s *MemoryCounterStore) Save(keyID string, c uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counters == nil {
+		s.counters = make(map[string]uint64)
+	}
+	s.counters[keyID] = c
+	return nil
+}
+
+// Like Verify, but with the counter loaded from s for keyID rather than taken
+// from the receiver HOTPKey, whose Counter is ignored. On a match, the
+// resynchronized counter is saved to s, so that the code cannot be used
+// again. Errors from s are returned as is. Loading and saving are separate
+// calls, so concurrent verifications for the same keyID must be serialized by
+// the caller, lest the same code be accepted twice.
+func (k *HOTPKey) VerifyWithStore(keyID, code string, lookAhead uint,
+	s CounterStore) (bool, error) {
+	c, err := s.Load(keyID)
+	if err != nil {
+		return false, err
+	}
+	h := k.Clone()
+	h.Counter = c
+	ok, next := h.Verify(code, lookAhead)
+	if !ok {
+		return false, nil
+	}
+	if err := s.Save(keyID, next); err != nil {
+		return false, err
+	}
+	return true, nil
+}
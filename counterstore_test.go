@@ -0,0 +1,63 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVerifyWithStore(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 500}
+	var s MemoryCounterStore
+	if _, err := s.Load("alice"); err != ErrNoCounter {
+		t.Fatalf("Failure: empty store: got %v, want %v", err, ErrNoCounter)
+	}
+	if ok, err := k.VerifyWithStore("alice", "755224", 3, &s); ok || err != ErrNoCounter {
+		t.Errorf("Failure: unknown key: got (%v, %v), want (false, %v)", ok, err, ErrNoCounter)
+	}
+	if err := s.Save("alice", 0); err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+
+	// RFC 4226 appendix D: codes for counters 0, 3, 2, 7, and 4. The store's
+	// counter, not the key's, is used, and is resynced past each match.
+	w := []struct {
+		code   string
+		ok     bool
+		stored uint64
+	}{
+		{"755224", true, 1},
+		{"755224", false, 1},
+		{"969429", true, 4},
+		{"359152", false, 4},
+		{"162583", false, 4},
+		{"338314", true, 5},
+	}
+	for _, v := range w {
+		ok, err := k.VerifyWithStore("alice", v.code, 2, &s)
+		if ok != v.ok || err != nil {
+			t.Errorf("Failure: code %s: got (%v, %v), want (%v, nil)", v.code, ok, err, v.ok)
+		}
+		if c, _ := s.Load("alice"); c != v.stored {
+			t.Errorf("Mismatch on stored counter after %s\nWant: %d Got: %d", v.code, v.stored, c)
+		}
+	}
+	if k.Counter != 500 {
+		t.Errorf("Failure: receiver modified to counter %d", k.Counter)
+	}
+
+	// Errors from the store are returned.
+	fail := errors.New("store unavailable")
+	if ok, err := k.VerifyWithStore("alice", "287922", 1, failingStore{&s, fail}); ok || err != fail {
+		t.Errorf("Failure: failing save: got (%v, %v), want (false, %v)", ok, err, fail)
+	}
+}
+
+// Loads from a MemoryCounterStore, but fails to save.
+type failingStore struct {
+	*MemoryCounterStore
+	err error
+}
+
+func (s failingStore) Save(string, uint64) error {
+	return s.err
+}
@@ -0,0 +1,77 @@
+package otp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Returns a human-readable breakdown of how the OTP for time at is computed,
+// for finding why a code does not match, such as by cross-checking each step
+// against another implementation: the time-step counter, the counter bytes
+// and HMAC in hexadecimal, the truncation offset and truncated value, and the
+// OTP. It is diagnostic only, and slower than OTPAt; neither its format nor
+// its output for invalid keys, which describes the error, is stable. The
+// secret-key is not included.
+func (k *TOTPKey) Debug(at time.Time) string {
+	if err := k.ValidateDetailed(); err != nil {
+		return fmt.Sprintf("invalid key: %v", err)
+	}
+	c, err := k.CounterAt(at)
+	if err != nil {
+		return fmt.Sprintf("no counter at %d: %v", at.Unix(), err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "time:      %d\n", at.Unix())
+	if k.ClockOffset != 0 {
+		fmt.Fprintf(&b, "offset by: %v\n", k.ClockOffset)
+	}
+	fmt.Fprintf(&b, "step:      (%d - %d) / %d\n", k.tokenTime(at).Unix(), k.T0, k.TimeStep)
+	b.WriteString(k.hotp(c).debug(c))
+	return b.String()
+}
+
+// The HOTP part of TOTPKey.Debug, for counter.
+func (k *HOTPKey) debug(counter uint64) string {
+	g, err := k.generator()
+	if err != nil {
+		return fmt.Sprintf("invalid key: %v\n", err)
+	}
+	ctr := counterBytes(counter)
+	g.mac.Reset()
+	g.mac.Write([]byte(k.Domain))
+	g.mac.Write(ctr[:])
+	sum := g.mac.Sum(nil)
+
+	var b strings.Builder
+	if k.Domain != "" {
+		fmt.Fprintf(&b, "domain:    %q\n", k.Domain)
+	}
+	fmt.Fprintf(&b, "counter:   %d (%x)\n", counter, ctr)
+	fmt.Fprintf(&b, "HMAC:      %x (%s)\n", sum, hashName(k.HashFunction))
+	if len(sum) < minDigestSize {
+		fmt.Fprintf(&b, "error:     %v\n", ErrShortDigest)
+		return b.String()
+	}
+	kind, offset := "dynamic", int(sum[len(sum)-1]&0x0F)
+	if k.TruncationOffset != nil {
+		kind, offset = "fixed", *k.TruncationOffset
+	}
+	fmt.Fprintf(&b, "offset:    %d (%s)\n", offset, kind)
+	v, _ := truncation(sum, offset)
+	fmt.Fprintf(&b, "truncated: %d (%08x)\n", v, v)
+	otp, err := g.generate(counter)
+	if err != nil {
+		fmt.Fprintf(&b, "error:     %v\n", err)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "code:      %s\n", otp)
+	return b.String()
+}
+
+func hashName(hf HashFunction) HashFunction {
+	if hf == "" {
+		return DefaultHash
+	}
+	return hf
+}
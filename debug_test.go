@@ -0,0 +1,42 @@
+package otp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebug(t *testing.T) {
+	// RFC 6238 appendix B, time 59, whose counter 1 gives the HMAC and
+	// truncation of RFC 4226 appendix D.
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	want := `time:      59
+step:      (59 - 0) / 30
+counter:   1 (0000000000000001)
+HMAC:      75a48a19d4cbe100644e8ac1397eea747a2d33ab (SHA1)
+offset:    11 (dynamic)
+truncated: 1094287082 (41397eea)
+code:      94287082
+`
+	if got := k.Debug(time.Unix(59, 0)); got != want {
+		t.Errorf("Mismatch on Debug:\nWant: %s Got: %s", want, got)
+	}
+	if got := k.Debug(time.Unix(59, 0)); strings.Contains(got, k.SecretKey) {
+		t.Errorf("Failure: Debug includes the secret-key")
+	}
+
+	k.ClockOffset = 30 * time.Second
+	if got := k.Debug(time.Unix(29, 0)); !strings.Contains(got, "offset by: 30s\n") || !strings.HasSuffix(got, "code:      94287082\n") {
+		t.Errorf("Failure: Debug with a clock offset: %s", got)
+	}
+
+	k.Digits = 0
+	if got := k.Debug(time.Unix(59, 0)); !strings.HasPrefix(got, "invalid key: ") {
+		t.Errorf("Failure: Debug of an invalid key: %s", got)
+	}
+	k.Digits = 8
+	k.T0 = 100
+	if got := k.Debug(time.Unix(59, 0)); !strings.HasPrefix(got, "no counter at 59: ") {
+		t.Errorf("Failure: Debug before T0: %s", got)
+	}
+}
@@ -0,0 +1,52 @@
+package otp
+
+import (
+	"errors"
+	"math"
+)
+
+// The number of bits of the value from which OTPs are formatted.
+const valueBits = 31
+
+// Returned by EntropyWarning when OTPs have more digits than the truncated
+// value can fill. Such keys are valid, but no more secure for the extra digit.
+var ErrExcessDigits = errors.New("otp: digits exceed the entropy of the truncated value")
+
+// Returns the entropy, in bits, of the OTPs of the HOTP parameter-set: that of
+// Digits digits in its output encoding, but at most the 31 bits of the
+// truncated value. For example, 6 decimal digits hold about 19.9 bits, and 10
+// only 31, not 33.2.
+func (k *HOTPKey) EntropyBits() float64 {
+	return math.Min(k.digitBits(), valueBits)
+}
+
+// Returns the entropy, in bits, of the OTPs of the TOTP parameter-set. See
+// HOTPKey.EntropyBits.
+func (k *TOTPKey) EntropyBits() float64 {
+	return k.hotp(0).EntropyBits()
+}
+
+// Returns ErrExcessDigits if Digits exceeds what the truncated value can
+// fill, as for 10 decimal digits, whose leading digit is always 0, 1, or 2,
+// and nil otherwise. Unlike ValidateDetailed, this is only a warning.
+func (k *HOTPKey) EntropyWarning() error {
+	if k.digitBits() > valueBits {
+		return ErrExcessDigits
+	}
+	return nil
+}
+
+// Returns ErrExcessDigits if Digits exceeds what the truncated value can fill.
+// See HOTPKey.EntropyWarning.
+func (k *TOTPKey) EntropyWarning() error {
+	return k.hotp(0).EntropyWarning()
+}
+
+// Returns the number of bits that Digits digits of the output encoding could
+// hold, were the truncated value not limited to 31 bits.
+func (k *HOTPKey) digitBits() float64 {
+	if k.Encoding == Hex {
+		return 4 * float64(k.Digits)
+	}
+	return math.Log2(10) * float64(k.Digits)
+}
@@ -0,0 +1,45 @@
+package otp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEntropyBits(t *testing.T) {
+	w := []struct {
+		digits   byte
+		encoding OutputEncoding
+		bits     float64
+		warning  error
+	}{
+		{6, Decimal, 6 * math.Log2(10), nil},
+		{8, Decimal, 8 * math.Log2(10), nil},
+		{9, Decimal, 9 * math.Log2(10), nil},
+		{10, Decimal, 31, ErrExcessDigits},
+		{6, Hex, 24, nil},
+		{7, Hex, 28, nil},
+		{8, Hex, 31, ErrExcessDigits},
+	}
+	for _, v := range w {
+		k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: v.digits, Encoding: v.encoding}
+		if b := k.EntropyBits(); b != v.bits {
+			t.Errorf("Mismatch on key %+v\nWant: %v Got: %v", k, v.bits, b)
+		}
+		if err := k.EntropyWarning(); err != v.warning {
+			t.Errorf("Failure on key %+v: got warning %v, want %v", k, err, v.warning)
+		}
+		// The warning is not an error.
+		if !k.Validate() {
+			t.Errorf("Failure: key %+v is invalid", k)
+		}
+	}
+
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 10, TimeStep: 30}
+	if b, err := k.EntropyBits(), k.EntropyWarning(); b != 31 || err != ErrExcessDigits {
+		t.Errorf("Failure: 10-digit TOTP key: got (%v, %v), want (31, %v)", b, err, ErrExcessDigits)
+	}
+	k.Digits = 6
+	if err := k.EntropyWarning(); err != nil {
+		t.Errorf("Failure: 6-digit TOTP key: got warning %v", err)
+	}
+}
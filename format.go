@@ -0,0 +1,135 @@
+package otp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stands in for secret-keys in String and GoString.
+const redacted = "****"
+
+// Formats the HOTP parameter-set with its secret-key redacted, so that keys
+// can be logged safely. The value receiver makes this hold for both HOTPKey
+// and *HOTPKey.
+func (k HOTPKey) String() string {
+	return k.format(redacted)
+}
+
+// Like String, so that the %#v verb also redacts the secret-key.
+func (k HOTPKey) GoString() string {
+	return k.format(redacted)
+}
+
+// Formats the HOTP parameter-set including its secret-key. This is for the
+// rare cases that genuinely need it; prefer String.
+func (k HOTPKey) StringWithSecret() string {
+	return k.format(k.SecretKey)
+}
+
+func (k HOTPKey) format(secret string) string {
+	offset := "dynamic"
+	if k.TruncationOffset != nil {
+		offset = fmt.Sprint(*k.TruncationOffset)
+	}
+	return fmt.Sprintf("HOTPKey{SecretKey:%s SecretEncoding:%d HashFunction:%s Digits:%d Counter:%d TruncationOffset:%s Encoding:%d%s}",
+		secret, k.SecretEncoding, k.HashFunction, k.Digits, k.Counter, offset, k.Encoding, formatDomain(k.Domain))
+}
+
+// Formats the TOTP parameter-set with its secret-key redacted, so that keys
+// can be logged safely. The value receiver makes this hold for both TOTPKey
+// and *TOTPKey.
+func (k TOTPKey) String() string {
+	return k.format(redacted)
+}
+
+// Like String, so that the %#v verb also redacts the secret-key.
+func (k TOTPKey) GoString() string {
+	return k.format(redacted)
+}
+
+// Formats the TOTP parameter-set including its secret-key. This is for the
+// rare cases that genuinely need it; prefer String.
+func (k TOTPKey) StringWithSecret() string {
+	return k.format(k.SecretKey)
+}
+
+func (k TOTPKey) format(secret string) string {
+	return fmt.Sprintf("TOTPKey{SecretKey:%s SecretEncoding:%d HashFunction:%s Digits:%d TimeStep:%d T0:%d ClockOffset:%s%s}",
+		secret, k.SecretEncoding, k.HashFunction, k.Digits, k.TimeStep, k.T0, k.ClockOffset, formatDomain(k.Domain))
+}
+
+// Formats a non-empty Domain as a trailing field; most keys have none.
+func formatDomain(d string) string {
+	if d == "" {
+		return ""
+	}
+	return fmt.Sprintf(" Domain:%q", d)
+}
+
+// Formats the OCRA parameter-set with its secret-key redacted.
+func (o OCRA) String() string {
+	return fmt.Sprintf("OCRA{SecretKey:%s SecretEncoding:%d Suite:%s}",
+		redacted, o.SecretEncoding, o.Suite)
+}
+
+// Like String, so that the %#v verb also redacts the secret-key.
+func (o OCRA) GoString() string {
+	return o.String()
+}
+
+// Formats the mOTP parameter-set with its secret and PIN redacted.
+func (k MOTPKey) String() string {
+	return "MOTPKey{Secret:" + redacted + " PIN:" + redacted + "}"
+}
+
+// Like String, so that the %#v verb also redacts the secret and PIN.
+func (k MOTPKey) GoString() string {
+	return k.String()
+}
+
+// Computes and returns the OTP, as OTP does, grouped for display: codes of
+// more than 4 characters are split in two with a space, the first group being
+// the shorter for odd lengths, such as "123 456" or "123 4567". Verify against
+// OTP, not this. If the receiver HOTPKey is invalid, OnInvalidKey is called.
+func (k *HOTPKey) OTPFormatted() string {
+	return groupCode(k.OTP())
+}
+
+// Computes and returns the current OTP grouped for display. See
+// HOTPKey.OTPFormatted.
+func (k *TOTPKey) OTPFormatted() string {
+	return groupCode(k.OTP())
+}
+
+func groupCode(code string) string {
+	if len(code) <= 4 {
+		return code
+	}
+	i := len(code) / 2
+	return code[:i] + " " + code[i:]
+}
+
+// Splits code into groups of groupSize characters, from the left, joined by
+// sep, such as "1234-5678" for FormatCode("12345678", 4, "-"). If the length
+// of code is not a multiple of groupSize, the last group is the shorter. A
+// groupSize that is not positive leaves code unchanged. Unlike OTPFormatted,
+// which splits codes in two, this is for fixed-size groups, and works on any
+// code, such as one received from a client. Verify against the ungrouped code.
+func FormatCode(code string, groupSize int, sep string) string {
+	if groupSize <= 0 || len(code) <= groupSize {
+		return code
+	}
+	var b strings.Builder
+	b.Grow(len(code) + (len(code)-1)/groupSize*len(sep))
+	for i := 0; i < len(code); i += groupSize {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		end := i + groupSize
+		if end > len(code) {
+			end = len(code)
+		}
+		b.WriteString(code[i:end])
+	}
+	return b.String()
+}
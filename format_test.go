@@ -0,0 +1,90 @@
+package otp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	hk := HOTPKey{SecretKey: secret, HashFunction: SHA1, Digits: 6, Counter: 1}
+	tk := TOTPKey{SecretKey: secret, HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	ok := OCRA{SecretKey: secret, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}
+	mk := MOTPKey{Secret: secret, PIN: secret}
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s"} {
+		for _, v := range []interface{}{hk, &hk, tk, &tk, ok, &ok, mk, &mk, []HOTPKey{hk}, map[string]*TOTPKey{"k": &tk}} {
+			if s := fmt.Sprintf(verb, v); strings.Contains(s, secret) {
+				t.Errorf("Failure: %s leaks the secret: %s", verb, s)
+			}
+		}
+	}
+
+	want := "HOTPKey{SecretKey:**** SecretEncoding:0 HashFunction:SHA1 Digits:6 Counter:1 TruncationOffset:dynamic Encoding:0}"
+	if s := hk.String(); s != want {
+		t.Errorf("Mismatch:\nWant: %s Got: %s", want, s)
+	}
+	if s := hk.StringWithSecret(); !strings.Contains(s, secret) {
+		t.Errorf("Failure: StringWithSecret omits the secret: %s", s)
+	}
+	if s := tk.StringWithSecret(); !strings.Contains(s, secret) {
+		t.Errorf("Failure: StringWithSecret omits the secret: %s", s)
+	}
+}
+
+func TestGroupCode(t *testing.T) {
+	w := []struct {
+		code, expect string
+	}{
+		{"", ""},
+		{"1234", "1234"},
+		{"12345", "12 345"},
+		{"123456", "123 456"},
+		{"1234567", "123 4567"},
+		{"12345678", "1234 5678"},
+		{"1234567890", "12345 67890"},
+	}
+	for _, v := range w {
+		if got := groupCode(v.code); got != v.expect {
+			t.Errorf("Mismatch on %q:\nWant: %q Got: %q", v.code, v.expect, got)
+		}
+	}
+
+	// RFC 4226 appendix D, counter 1.
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 1}
+	if got := k.OTPFormatted(); got != "287 082" || k.OTP() != "287082" {
+		t.Errorf("Mismatch on OTPFormatted:\nWant: %s Got: %s", "287 082", got)
+	}
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	if got := tk.OTPFormatted(); len(got) != 9 || got[4] != ' ' {
+		t.Errorf("Failure: OTPFormatted returned %q", got)
+	}
+}
+
+func TestFormatCode(t *testing.T) {
+	w := []struct {
+		code      string
+		groupSize int
+		sep       string
+		expect    string
+	}{
+		{"12345678", 4, "-", "1234-5678"},
+		{"123456", 3, " ", "123 456"},
+		{"123456", 2, " ", "12 34 56"},
+		// The last group is the shorter.
+		{"1234567", 4, "-", "1234-567"},
+		{"1234567890", 3, " ", "123 456 789 0"},
+		{"123456", 6, "-", "123456"},
+		{"123456", 8, "-", "123456"},
+		{"123456", 0, "-", "123456"},
+		{"123456", -1, "-", "123456"},
+		{"12345678", 4, "", "12345678"},
+		{"12345678", 4, " - ", "1234 - 5678"},
+		{"", 3, "-", ""},
+	}
+	for _, v := range w {
+		if got := FormatCode(v.code, v.groupSize, v.sep); got != v.expect {
+			t.Errorf("Mismatch on %q, %d, %q:\nWant: %q Got: %q", v.code, v.groupSize, v.sep, v.expect, got)
+		}
+	}
+}
@@ -1,3 +1,3 @@
 module codeberg.org/ar324/otp
 
-go 1.17
+go 1.18
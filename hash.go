@@ -0,0 +1,118 @@
+package otp
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+)
+
+var (
+	hfMu  sync.RWMutex
+	hfMap map[HashFunction]func() hash.Hash
+)
+
+func init() {
+	hfMap = make(map[HashFunction]func() hash.Hash)
+	hfMap[SHA1] = sha1.New
+	hfMap[SHA256] = sha256.New
+	hfMap[SHA512] = sha512.New
+	hfMap[SHA512_256] = sha512.New512_256
+}
+
+// Registers fn as the hash function for name, so that keys using name
+// validate. Hash functions whose digests are shorter than the 20 bytes that
+// dynamic truncation reads from are rejected with ErrShortDigest, so that keys
+// using them never validate only to fail when generating. It is safe to call
+// concurrently, but the safe pattern is to register from an init function,
+// before any key using name is in use. Overwriting a built-in hash function is
+// allowed but discouraged, as keys using it would silently change their OTPs.
+func RegisterHash(name HashFunction, fn func() hash.Hash) error {
+	// Size is only what the hash function claims; the digest is what counts.
+	if n := len(fn().Sum(nil)); n < minDigestSize {
+		return fmt.Errorf("%w: %s has a %d-byte digest", ErrShortDigest, name, n)
+	}
+	hfMu.Lock()
+	defer hfMu.Unlock()
+	hfMap[name] = fn
+	return nil
+}
+
+// Returns the names of the registered hash functions, built-in ones included,
+// in sorted order, such as for listing the valid choices. The slice is a copy,
+// and may be modified by the caller.
+func RegisteredHashes() []HashFunction {
+	hfMu.RLock()
+	names := make([]HashFunction, 0, len(hfMap))
+	for name := range hfMap {
+		names = append(names, name)
+	}
+	hfMu.RUnlock()
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// Returns the hash function registered for name, or nil if there is none. The
+// empty name is taken to be DefaultHash.
+func lookupHash(name HashFunction) func() hash.Hash {
+	if name == "" {
+		name = DefaultHash
+	}
+	hfMu.RLock()
+	defer hfMu.RUnlock()
+	return hfMap[name]
+}
+
+// A hash function set on a key with SetHash. It is held by pointer, so that
+// keys remain comparable.
+type hashFactory struct {
+	fn func() hash.Hash
+}
+
+// Sets fn as the hash function of the key's HMAC, in place of looking up
+// HashFunction, so that hash functions can be used without registering them.
+// As with RegisterHash, a hash function whose digest is shorter than 20 bytes
+// is rejected with ErrShortDigest. HashFunction is still what is marshalled,
+// so it should name fn; fn itself is not marshalled, so it must be set on a key
+// before unmarshalling into it, which keeps it. Passing nil restores
+// HashFunction.
+func (k *HOTPKey) SetHash(fn func() hash.Hash) error {
+	hf, err := newHashFactory(fn)
+	if err != nil {
+		return err
+	}
+	k.hashFn = hf
+	return nil
+}
+
+// Sets fn as the hash function of the key's HMAC. See HOTPKey.SetHash.
+func (k *TOTPKey) SetHash(fn func() hash.Hash) error {
+	hf, err := newHashFactory(fn)
+	if err != nil {
+		return err
+	}
+	k.hashFn = hf
+	return nil
+}
+
+func newHashFactory(fn func() hash.Hash) (*hashFactory, error) {
+	if fn == nil {
+		return nil, nil
+	}
+	if n := len(fn().Sum(nil)); n < minDigestSize {
+		return nil, fmt.Errorf("%w: hash function has a %d-byte digest", ErrShortDigest, n)
+	}
+	return &hashFactory{fn: fn}, nil
+}
+
+// Returns the hash function set with SetHash, if any, and otherwise the one
+// registered for HashFunction, or nil if there is none.
+func (k *HOTPKey) hashFunc() func() hash.Hash {
+	if k.hashFn != nil {
+		return k.hashFn.fn
+	}
+	return lookupHash(k.HashFunction)
+}
@@ -0,0 +1,174 @@
+package otp
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterHash(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", HashFunction: "SHA224", Digits: 8, Counter: 1}
+	if k.Validate() {
+		t.Fatalf("Failure: key with unregistered hash marked as valid: %+v", k)
+	}
+
+	if err := RegisterHash("SHA224", sha256.New224); err != nil {
+		t.Fatalf("Failure: registering SHA224: %v", err)
+	}
+	defer func() {
+		hfMu.Lock()
+		delete(hfMap, "SHA224")
+		hfMu.Unlock()
+	}()
+	if !k.Validate() {
+		t.Fatalf("Failure: key with registered hash marked as invalid: %+v", k)
+	}
+	if _, err := k.Generate(); err != nil {
+		t.Errorf("Failure: key %+v: %v", k, err)
+	}
+}
+
+func TestRegisteredHashes(t *testing.T) {
+	got := RegisteredHashes()
+	if !sort.SliceIsSorted(got, func(i, j int) bool { return got[i] < got[j] }) {
+		t.Errorf("Failure: unsorted hashes %v", got)
+	}
+	for _, hf := range []HashFunction{SHA1, SHA256, SHA512, SHA512_256} {
+		found := false
+		for _, v := range got {
+			found = found || v == hf
+		}
+		if !found {
+			t.Errorf("Failure: built-in %s missing from %v", hf, got)
+		}
+	}
+	// The result is a copy.
+	got[0] = "modified"
+	if RegisteredHashes()[0] == "modified" {
+		t.Errorf("Failure: RegisteredHashes shares its slice")
+	}
+}
+
+func TestSHA512_256(t *testing.T) {
+	// The RFC 6238 appendix B "T" values, with SHA-512/256 and the 32-byte
+	// seed, computed with Python's hmac and hashlib.new("sha512_256").
+	w := []struct {
+		counter uint64
+		expect  string
+	}{
+		{0x1, "00441233"},
+		{0x23523EC, "97406494"},
+		{0x27BC86AA, "83211259"},
+	}
+	for _, v := range w {
+		if got := ReferenceVector(SHA512_256, 8, v.counter); got != v.expect {
+			t.Errorf("Mismatch on counter %d:\nWant: %s Got: %s", v.counter, v.expect, got)
+		}
+	}
+	if n := MinKeySizeFor(SHA512_256); n != 32 {
+		t.Errorf("Mismatch on MinKeySizeFor\nWant: %d Got: %d", 32, n)
+	}
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA", HashFunction: "sha512_256", Digits: 8, TimeStep: 30}
+	if err := k.ValidateDetailed(); err != ErrUnknownHash {
+		t.Errorf("Failure: lowercase name: got %v, want %v", err, ErrUnknownHash)
+	}
+	k.HashFunction = canonicalHash(k.HashFunction)
+	if err := k.ValidateDetailed(); err != nil {
+		t.Errorf("Failure: %v", err)
+	}
+	if got := k.OTPAt(time.Unix(59, 0)); got != "00441233" {
+		t.Errorf("Mismatch on time 59:\nWant: %s Got: %s", "00441233", got)
+	}
+}
+
+func TestSetHash(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", HashFunction: "SHA224", Digits: 8, Counter: 1}
+	if err := k.SetHash(sha256.New224); err != nil {
+		t.Fatalf("Failure: setting SHA224: %v", err)
+	}
+	if lookupHash("SHA224") != nil {
+		t.Fatalf("Failure: SetHash registered SHA224")
+	}
+	if err := k.ValidateDetailed(); err != nil {
+		t.Fatalf("Failure: key with a set hash is invalid: %v", err)
+	}
+	otp, err := k.Generate()
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+
+	// The same hash function, registered, yields the same OTP.
+	if err := RegisterHash("SHA224", sha256.New224); err != nil {
+		t.Fatalf("Failure: registering SHA224: %v", err)
+	}
+	defer func() {
+		hfMu.Lock()
+		delete(hfMap, "SHA224")
+		hfMu.Unlock()
+	}()
+	r := k
+	r.SetHash(nil)
+	if got := r.OTP(); got != otp {
+		t.Errorf("Mismatch on registered SHA224:\nWant: %s Got: %s", otp, got)
+	}
+
+	// A set hash function takes precedence over HashFunction.
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	if err := tk.SetHash(sha256.New); err != nil {
+		t.Fatalf("Failure: setting SHA256: %v", err)
+	}
+	tk2 := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA256, Digits: 8, TimeStep: 30}
+	if got, want := tk.OTPAt(time.Unix(59, 0)), tk2.OTPAt(time.Unix(59, 0)); got != want {
+		t.Errorf("Mismatch on set SHA256:\nWant: %s Got: %s", want, got)
+	}
+	if tc := tk.Clone(); *tc != tk {
+		t.Errorf("Failure: clone of a key with a set hash differs: %+v", tc)
+	}
+
+	if err := k.SetHash(md5.New); !errors.Is(err, ErrShortDigest) {
+		t.Errorf("Failure: setting MD5: got %v, want %v", err, ErrShortDigest)
+	}
+	if err := tk.SetHash(md5.New); !errors.Is(err, ErrShortDigest) {
+		t.Errorf("Failure: setting MD5: got %v, want %v", err, ErrShortDigest)
+	}
+}
+
+func TestRegisterShortHash(t *testing.T) {
+	// MD5's 16-byte digest is too short for dynamic truncation.
+	if err := RegisterHash("MD5", md5.New); !errors.Is(err, ErrShortDigest) {
+		t.Errorf("Failure: registering MD5: got %v, want %v", err, ErrShortDigest)
+	}
+	if lookupHash("MD5") != nil {
+		hfMu.Lock()
+		delete(hfMap, "MD5")
+		hfMu.Unlock()
+		t.Fatalf("Failure: MD5 was registered")
+	}
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: "MD5", Digits: 6}
+	if err := k.ValidateDetailed(); err != ErrUnknownHash {
+		t.Errorf("Failure: key using MD5: got %v, want %v", err, ErrUnknownHash)
+	}
+}
+
+func TestDefaultHash(t *testing.T) {
+	implicit := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Digits: 8, Counter: 1}
+	explicit := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, Counter: 1}
+	if !implicit.Validate() {
+		t.Fatalf("Failure: key without a hash function marked as invalid: %+v", implicit)
+	}
+	if implicit.OTP() != explicit.OTP() {
+		t.Errorf("Mismatch between %+v and %+v:\n%s != %s", implicit, explicit, implicit.OTP(), explicit.OTP())
+	}
+
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Digits: 8, TimeStep: 30}
+	if otp := tk.OTPAt(time.Unix(59, 0)); otp != "94287082" {
+		t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", tk, "94287082", otp)
+	}
+	if uri := tk.URI("", "alice"); !strings.Contains(uri, "algorithm=SHA1") {
+		t.Errorf("Failure: URI lacks the default algorithm: %s", uri)
+	}
+}
@@ -0,0 +1,79 @@
+package otp
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Decodes an HOTP parameter-set from JSON, matching HashFunction
+// case-insensitively, and validates it. An invalid parameter-set yields
+// ErrInvalidHOTPKey, rather than a key that panics when used. The settings of
+// the receiver's Set methods, such as SetBase32Encoding, are kept, and
+// validation uses them.
+func (k *HOTPKey) UnmarshalJSON(data []byte) error {
+	// The alias type has no methods, so this does not recurse.
+	type hotpKey HOTPKey
+	var v hotpKey
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	(*HOTPKey)(&v).copySettings(k)
+	v.HashFunction = canonicalHash(v.HashFunction)
+	if !(*HOTPKey)(&v).Validate() {
+		return ErrInvalidHOTPKey
+	}
+	*k = HOTPKey(v)
+	return nil
+}
+
+// Decodes a TOTP parameter-set from JSON, matching HashFunction
+// case-insensitively, and validates it. An invalid parameter-set yields
+// ErrInvalidTOTPKey, rather than a key that panics when used. As for HOTPKey,
+// the settings of the receiver's Set methods are kept.
+func (k *TOTPKey) UnmarshalJSON(data []byte) error {
+	type totpKey TOTPKey
+	var v totpKey
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	(*TOTPKey)(&v).copySettings(k)
+	v.HashFunction = canonicalHash(v.HashFunction)
+	if !(*TOTPKey)(&v).Validate() {
+		return ErrInvalidTOTPKey
+	}
+	*k = TOTPKey(v)
+	return nil
+}
+
+// Encodes the HOTP parameter-set as JSON without its secret-key, for logging
+// or exporting the metadata of keys: only "hash_function", "digits", and
+// "counter" are included. The result cannot be decoded into a valid key; use
+// json.Marshal to persist keys.
+func (k *HOTPKey) MarshalJSONPublic() ([]byte, error) {
+	return json.Marshal(struct {
+		HashFunction HashFunction `json:"hash_function"`
+		Digits       byte         `json:"digits"`
+		Counter      uint64       `json:"counter"`
+	}{k.HashFunction, k.Digits, k.Counter})
+}
+
+// Encodes the TOTP parameter-set as JSON without its secret-key: only
+// "hash_function", "digits", "time_step", and "t0" are included. See
+// HOTPKey.MarshalJSONPublic.
+func (k *TOTPKey) MarshalJSONPublic() ([]byte, error) {
+	return json.Marshal(struct {
+		HashFunction HashFunction `json:"hash_function"`
+		Digits       byte         `json:"digits"`
+		TimeStep     uint64       `json:"time_step"`
+		T0           uint64       `json:"t0"`
+	}{k.HashFunction, k.Digits, k.TimeStep, k.T0})
+}
+
+// Returns the registered spelling of hf, which is looked up as is and then
+// uppercased.
+func canonicalHash(hf HashFunction) HashFunction {
+	if lookupHash(hf) != nil {
+		return hf
+	}
+	return HashFunction(strings.ToUpper(string(hf)))
+}
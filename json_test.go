@@ -0,0 +1,142 @@
+package otp
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalJSON(t *testing.T) {
+	var tk TOTPKey
+	data := `{"secret_key":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","hash_function":"sha256","digits":8,"time_step":30,"t0":0}`
+	if err := json.Unmarshal([]byte(data), &tk); err != nil {
+		t.Fatalf("Failure: %s: %v", data, err)
+	}
+	want := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA256, Digits: 8, TimeStep: 30}
+	if tk != want {
+		t.Errorf("Mismatch on %s:\nWant: %+v Got: %+v", data, want, tk)
+	}
+
+	var hk HOTPKey
+	data = `{"secret_key":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","hash_function":"Sha1","digits":6,"counter":7}`
+	if err := json.Unmarshal([]byte(data), &hk); err != nil {
+		t.Fatalf("Failure: %s: %v", data, err)
+	}
+	if hk.HashFunction != SHA1 || hk.Counter != 7 {
+		t.Errorf("Mismatch on %s: got %+v", data, hk)
+	}
+
+	// Marshalling and unmarshalling must round-trip.
+	b, err := json.Marshal(&want)
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	tk = TOTPKey{}
+	if err := json.Unmarshal(b, &tk); err != nil || tk != want {
+		t.Errorf("Mismatch on round trip of %s: got %+v (%v)", b, tk, err)
+	}
+
+	invalidTOTP := []string{
+		`{"secret_key":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","hash_function":"SHA1","digits":6,"time_step":0}`,
+		`{"secret_key":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","hash_function":"MD5","digits":6,"time_step":30}`,
+		`{"secret_key":"NOTBASE32 . . .","hash_function":"SHA1","digits":6,"time_step":30}`,
+	}
+	for _, v := range invalidTOTP {
+		tk = TOTPKey{}
+		if err := json.Unmarshal([]byte(v), &tk); err != ErrInvalidTOTPKey {
+			t.Errorf("Failure: %s: got error %v, want %v", v, err, ErrInvalidTOTPKey)
+		}
+		if tk != (TOTPKey{}) {
+			t.Errorf("Failure: %s: receiver modified to %+v", v, tk)
+		}
+	}
+	v := `{"secret_key":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","hash_function":"SHA1","digits":11}`
+	if err := json.Unmarshal([]byte(v), &hk); err != ErrInvalidHOTPKey {
+		t.Errorf("Failure: %s: got error %v, want %v", v, err, ErrInvalidHOTPKey)
+	}
+	if err := json.Unmarshal([]byte(`{"digits":"six"}`), &hk); err == nil {
+		t.Errorf("Failure: malformed JSON accepted")
+	}
+}
+
+func TestMarshalJSONPublic(t *testing.T) {
+	hk := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 7}
+	b, err := hk.MarshalJSONPublic()
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if want := `{"hash_function":"SHA1","digits":6,"counter":7}`; string(b) != want {
+		t.Errorf("Mismatch on public HOTPKey:\nWant: %s Got: %s", want, b)
+	}
+
+	tk := TOTPKey{SecretKey: "12345678901234567890", SecretEncoding: EncodingRaw, HashFunction: SHA256, Digits: 8, TimeStep: 30, T0: 5}
+	b, err = tk.MarshalJSONPublic()
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if want := `{"hash_function":"SHA256","digits":8,"time_step":30,"t0":5}`; string(b) != want {
+		t.Errorf("Mismatch on public TOTPKey:\nWant: %s Got: %s", want, b)
+	}
+	for _, s := range []string{tk.SecretKey, "secret", "GEZDGNBV", "MTIzNDU2"} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("Failure: public form %s contains %q", b, s)
+		}
+	}
+}
+
+// An alphabet under which the RFC 4226 secret-key encodes to a string that
+// also decodes, to another secret-key, under the standard one.
+var reversedBase32 = base32.NewEncoding("ZYXWVUTSRQPONMLKJIHGFEDCBA765432")
+
+// Returns an HOTP key, at counter 1, with the RFC 4226 secret-key encoded with
+// reversedBase32, whose OTP is therefore "94287082".
+func reversedAlphabetKey() *HOTPKey {
+	k := &HOTPKey{SecretKey: reversedBase32.EncodeToString([]byte("12345678901234567890")), HashFunction: SHA1, Digits: 8, Counter: 1}
+	k.SetBase32Encoding(reversedBase32)
+	return k
+}
+
+func TestUnmarshalJSONKeepsSettings(t *testing.T) {
+	hk := reversedAlphabetKey()
+	data, err := json.Marshal(hk)
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	var got HOTPKey
+	got.SetBase32Encoding(reversedBase32)
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Failure: %s: %v", data, err)
+	}
+	if otp := got.OTP(); otp != "94287082" {
+		t.Errorf("Mismatch on custom alphabet\nWant: %s Got: %s", "94287082", otp)
+	}
+	// Without the alphabet, the secret-key is not the same one.
+	var std HOTPKey
+	if err := json.Unmarshal(data, &std); err == nil && std.OTP() == "94287082" {
+		t.Errorf("Failure: standard alphabet decoded the custom secret-key")
+	}
+
+	tk := TOTPKey{SecretKey: hk.SecretKey, HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	data, _ = json.Marshal(&tk)
+	var gotT TOTPKey
+	gotT.SetBase32Encoding(reversedBase32)
+	if err := json.Unmarshal(data, &gotT); err != nil {
+		t.Fatalf("Failure: %s: %v", data, err)
+	}
+	if otp := gotT.OTPAt(time.Unix(59, 0)); otp != "94287082" {
+		t.Errorf("Mismatch on custom alphabet\nWant: %s Got: %s", "94287082", otp)
+	}
+
+	// Short secret-keys are allowed if the receiver allows them.
+	data = []byte(`{"secret_key":"GEZDGNBV","hash_function":"SHA1","digits":6,"counter":0}`)
+	var short HOTPKey
+	if err := json.Unmarshal(data, &short); err != ErrInvalidHOTPKey {
+		t.Errorf("Failure: short secret-key: got %v, want %v", err, ErrInvalidHOTPKey)
+	}
+	short.SetAllowShortSecret(true)
+	if err := json.Unmarshal(data, &short); err != nil {
+		t.Errorf("Failure: allowed short secret-key: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// The scrypt cost parameters of SecretFromPassphrase: N = 2^15, r = 8, and
+// p = 1, which take 32 MiB of memory. Changing them changes every derived
+// secret-key.
+const (
+	passphraseCost        = 1 << 15
+	passphraseBlockSize   = 8
+	passphraseParallelism = 1
+)
+
+// Returned by SecretFromPassphrase for an empty passphrase.
+var ErrEmptyPassphrase = errors.New("otp: passphrase is empty")
+
+// Derives a secret-key of the given number of bytes from passphrase and salt
+// with scrypt, and returns it base-32 encoded, so that it can be regenerated
+// from the passphrase rather than stored. The same inputs always yield the
+// same secret-key. Sizes below MinKeySize are rejected.
+//
+// The secret-key is only as strong as the passphrase: anyone who learns or
+// guesses it can derive every secret-key, and scrypt only slows guessing
+// down, so the passphrase must be long and random. The salt should be unique
+// to each key, such as the issuer and account name, so that one passphrase
+// yields unrelated secret-keys, and precomputed guesses are useless. A
+// derived secret-key cannot be rotated without changing the passphrase or
+// salt; generate secret-keys with GenerateSecret wherever they can be stored.
+func SecretFromPassphrase(passphrase, salt string, bytes int) (string, error) {
+	if bytes < MinKeySize {
+		return "", fmt.Errorf("otp: secret size %d is below MinKeySize", bytes)
+	}
+	if passphrase == "" {
+		return "", ErrEmptyPassphrase
+	}
+	b := scrypt([]byte(passphrase), []byte(salt), passphraseCost,
+		passphraseBlockSize, passphraseParallelism, bytes)
+	return base32.StdEncoding.EncodeToString(b), nil
+}
+
+// Derives keyLen bytes from password and salt, as described in RFC 7914,
+// with the CPU/memory cost n, which must be a power of 2, the block size r,
+// and the parallelization p.
+func scrypt(password, salt []byte, n, r, p, keyLen int) []byte {
+	b := pbkdf2SHA256(password, salt, p*128*r)
+	xy := make([]uint32, 64*r)
+	v := make([]uint32, 32*n*r)
+	for i := 0; i < p; i++ {
+		roMix(b[i*128*r:], r, n, v, xy)
+	}
+	return pbkdf2SHA256(password, b, keyLen)
+}
+
+// PBKDF2 with HMAC-SHA256 and a single iteration, which is all scrypt uses.
+func pbkdf2SHA256(password, salt []byte, keyLen int) []byte {
+	mac := hmac.New(sha256.New, password)
+	dk := make([]byte, 0, keyLen+sha256.Size)
+	var ctr [4]byte
+	for i := uint32(1); len(dk) < keyLen; i++ {
+		mac.Reset()
+		mac.Write(salt)
+		binary.BigEndian.PutUint32(ctr[:], i)
+		mac.Write(ctr[:])
+		dk = mac.Sum(dk)
+	}
+	return dk[:keyLen]
+}
+
+// Mixes the 128*r bytes of b in place, with v and xy as scratch space.
+func roMix(b []byte, r, n int, v, xy []uint32) {
+	x, y := xy[:32*r], xy[32*r:]
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	for i := 0; i < n; i++ {
+		copy(v[i*32*r:], x)
+		blockMix(x, y, r)
+		x, y = y, x
+	}
+	for i := 0; i < n; i++ {
+		j := int(x[(2*r-1)*16]) & (n - 1)
+		for k, w := range v[j*32*r : (j+1)*32*r] {
+			x[k] ^= w
+		}
+		blockMix(x, y, r)
+		x, y = y, x
+	}
+	for i, w := range x {
+		binary.LittleEndian.PutUint32(b[4*i:], w)
+	}
+}
+
+// Mixes the 2*r 64-byte blocks of in into out, even blocks first.
+func blockMix(in, out []uint32, r int) {
+	var t [16]uint32
+	copy(t[:], in[(2*r-1)*16:])
+	for i := 0; i < 2*r; i++ {
+		for k := range t {
+			t[k] ^= in[i*16+k]
+		}
+		salsa208(&t)
+		copy(out[((i&1)*r+i/2)*16:], t[:])
+	}
+}
+
+// Applies the Salsa20/8 core to b.
+func salsa208(b *[16]uint32) {
+	x := *b
+	qr := func(a, b, c, d int) {
+		x[b] ^= bits.RotateLeft32(x[a]+x[d], 7)
+		x[c] ^= bits.RotateLeft32(x[b]+x[a], 9)
+		x[d] ^= bits.RotateLeft32(x[c]+x[b], 13)
+		x[a] ^= bits.RotateLeft32(x[d]+x[c], 18)
+	}
+	for i := 0; i < 8; i += 2 {
+		qr(0, 4, 8, 12)
+		qr(5, 9, 13, 1)
+		qr(10, 14, 2, 6)
+		qr(15, 3, 7, 11)
+		qr(0, 1, 2, 3)
+		qr(5, 6, 7, 4)
+		qr(10, 11, 8, 9)
+		qr(15, 12, 13, 14)
+	}
+	for i := range b {
+		b[i] += x[i]
+	}
+}
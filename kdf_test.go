@@ -0,0 +1,59 @@
+package otp
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"testing"
+)
+
+func TestScrypt(t *testing.T) {
+	// The RFC 7914 section 12 test vectors.
+	w := []struct {
+		password, salt string
+		n, r, p        int
+		expect         string
+	}{
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+		{"pleaseletmein", "SodiumChloride", 16384, 8, 1, "7023bdcb3afd7348461c06cd81fd38ebfda8fbba904f8e3ea9b543f6545da1f2d5432955613f0fcf62d49705242a9af9e61e85dc0d651e40dfcf017b45575887"},
+	}
+	for _, v := range w {
+		got := hex.EncodeToString(scrypt([]byte(v.password), []byte(v.salt), v.n, v.r, v.p, 64))
+		if got != v.expect {
+			t.Errorf("Mismatch on %q, %q:\nWant: %s Got: %s", v.password, v.salt, v.expect, got)
+		}
+	}
+}
+
+func TestSecretFromPassphrase(t *testing.T) {
+	a, err := SecretFromPassphrase("correct horse battery staple", "ACME:alice", 20)
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if want := "ZLKLJACRI5YIIMT5VXONXFJ5GPCXTGHO"; a != want {
+		t.Errorf("Mismatch on derived secret\nWant: %s Got: %s", want, a)
+	}
+	if again, _ := SecretFromPassphrase("correct horse battery staple", "ACME:alice", 20); again != a {
+		t.Errorf("Mismatch on repeated derivation\nWant: %s Got: %s", a, again)
+	}
+	if b, _ := SecretFromPassphrase("correct horse battery staple", "ACME:bob", 20); b == a {
+		t.Errorf("Failure: different salts yield the same secret %s", a)
+	}
+	if b, _ := SecretFromPassphrase("correct horse battery stapler", "ACME:alice", 20); b == a {
+		t.Errorf("Failure: different passphrases yield the same secret %s", a)
+	}
+	if b, _ := SecretFromPassphrase("correct horse battery staple", "ACME:alice", 32); len(b) == len(a) {
+		t.Errorf("Failure: different sizes yield secrets of the same length: %s", b)
+	} else if sk, err := base32.StdEncoding.DecodeString(b); err != nil || len(sk) != 32 {
+		t.Errorf("Failure: secret %q decodes to %d bytes (%v)", b, len(sk), err)
+	}
+
+	for _, n := range []int{-1, 0, MinKeySize - 1} {
+		if _, err := SecretFromPassphrase("correct horse battery staple", "ACME:alice", n); err == nil {
+			t.Errorf("Failure: size %d accepted", n)
+		}
+	}
+	if _, err := SecretFromPassphrase("", "ACME:alice", 20); err != ErrEmptyPassphrase {
+		t.Errorf("Failure: empty passphrase: got %v, want %v", err, ErrEmptyPassphrase)
+	}
+}
@@ -0,0 +1,245 @@
+package otp
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var ErrMalformedMigration = errors.New("otp: malformed migration payload")
+
+// Reports the entries of a migration payload that ParseMigration skipped, each
+// as an error naming the entry.
+type MigrationError []error
+
+func (e MigrationError) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return fmt.Sprintf("otp: skipped %d migration entries: %s", len(e),
+		strings.Join(s, "; "))
+}
+
+// An account of a migration payload, as returned by ParseMigrationEntries.
+type MigrationEntry struct {
+	// A *TOTPKey or *HOTPKey.
+	Key OTPGenerator
+	// The account's name, as the authenticator displayed it. Some exporters
+	// prefix it with the issuer, as in "issuer:account".
+	Name string
+	// The account's issuer, which may be empty.
+	Issuer string
+}
+
+// Parses an otpauth-migration://offline?data=... URI, as exported by Google
+// Authenticator, returning a *TOTPKey or *HOTPKey for each account in it. The
+// payload carries no time-step, so TOTP keys have DefaultTimeStep. Entries
+// that this package cannot represent, such as MD5 keys, are skipped: the keys
+// of the others are returned along with a MigrationError describing them. Use
+// ParseMigrationEntries to also get the name and issuer of each account.
+func ParseMigration(uri string) ([]OTPGenerator, error) {
+	entries, err := ParseMigrationEntries(uri)
+	if entries == nil {
+		return nil, err
+	}
+	keys := make([]OTPGenerator, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+	return keys, err
+}
+
+// Like ParseMigration, but returns each key along with the name and issuer of
+// its account, so that a bulk import can tell the accounts apart.
+func ParseMigrationEntries(uri string) ([]MigrationEntry, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("otp: malformed URI: %w", err)
+	}
+	if !strings.EqualFold(u.Scheme, "otpauth-migration") {
+		return nil, fmt.Errorf("otp: unsupported URI scheme %q", u.Scheme)
+	}
+	// A "+" that was not percent-encoded is decoded as a space.
+	data := strings.ReplaceAll(u.Query().Get("data"), " ", "+")
+	if data == "" {
+		return nil, fmt.Errorf("otp: URI is missing the data parameter")
+	}
+	enc := base64.StdEncoding
+	if len(data)%4 != 0 {
+		enc = base64.RawStdEncoding
+	}
+	b, err := enc.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedMigration, err)
+	}
+
+	var entries []MigrationEntry
+	var skipped MigrationError
+	// MigrationPayload: repeated OtpParameters otp_parameters = 1. Its other
+	// fields describe batching, and are of no use here.
+	err = parseProto(b, func(field int, _ uint64, p []byte) error {
+		if field != 1 || p == nil {
+			return nil
+		}
+		e, err := parseMigrationEntry(p)
+		if errors.Is(err, ErrMalformedMigration) {
+			return err
+		}
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("entry %d: %w",
+				len(entries)+len(skipped), err))
+			return nil
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if skipped != nil {
+		return entries, skipped
+	}
+	return entries, nil
+}
+
+// Parses an OtpParameters message:
+//
+//	bytes secret = 1;
+//	string name = 2;
+//	string issuer = 3;
+//	Algorithm algorithm = 4; // 0: unspecified, 1: SHA1, 2: SHA256, 3: SHA512, 4: MD5
+//	DigitCount digits = 5;   // 0: unspecified, 1: six, 2: eight
+//	OtpType type = 6;        // 0: unspecified, 1: HOTP, 2: TOTP
+//	int64 counter = 7;
+func parseMigrationEntry(b []byte) (MigrationEntry, error) {
+	var secret []byte
+	var name, issuer string
+	var alg, digits, typ, counter uint64
+	err := parseProto(b, func(field int, v uint64, p []byte) error {
+		switch field {
+		case 1:
+			secret = p
+		case 2:
+			name = string(p)
+		case 3:
+			issuer = string(p)
+		case 4:
+			alg = v
+		case 5:
+			digits = v
+		case 6:
+			typ = v
+		case 7:
+			counter = v
+		}
+		return nil
+	})
+	if err != nil {
+		return MigrationEntry{}, err
+	}
+
+	entryErr := func(err error) error {
+		return fmt.Errorf("%q (%s): %w", name, issuer, err)
+	}
+	entry := MigrationEntry{Name: name, Issuer: issuer}
+
+	var hf HashFunction
+	switch alg {
+	case 0, 1:
+		hf = SHA1
+	case 2:
+		hf = SHA256
+	case 3:
+		hf = SHA512
+	default:
+		return MigrationEntry{}, entryErr(ErrUnknownHash)
+	}
+	var d byte
+	switch digits {
+	case 0, 1:
+		d = 6
+	case 2:
+		d = 8
+	default:
+		return MigrationEntry{}, entryErr(ErrDigitsOutOfRange)
+	}
+	switch typ {
+	case 1:
+		k := HOTPKeyFromBytes(secret, hf, d, counter)
+		if err := k.ValidateDetailed(); err != nil {
+			return MigrationEntry{}, entryErr(err)
+		}
+		entry.Key = k
+	case 2:
+		k := TOTPKeyFromBytes(secret, hf, d, DefaultTimeStep, 0)
+		if err := k.ValidateDetailed(); err != nil {
+			return MigrationEntry{}, entryErr(err)
+		}
+		entry.Key = k
+	default:
+		return MigrationEntry{}, entryErr(fmt.Errorf("unsupported OTP type %d", typ))
+	}
+	return entry, nil
+}
+
+// Calls fn for each field of the protocol buffer message b, with the value of
+// varint fields as v, and the contents of length-delimited ones as p. Fixed-size
+// fields are skipped.
+func parseProto(b []byte, fn func(field int, v uint64, p []byte) error) error {
+	for len(b) > 0 {
+		tag, n := protoVarint(b)
+		if n == 0 {
+			return ErrMalformedMigration
+		}
+		b = b[n:]
+		field := int(tag >> 3)
+		var v uint64
+		var p []byte
+		switch tag & 7 {
+		case 0:
+			v, n = protoVarint(b)
+			if n == 0 {
+				return ErrMalformedMigration
+			}
+			b = b[n:]
+		case 1, 5:
+			n = 8
+			if tag&7 == 5 {
+				n = 4
+			}
+			if len(b) < n {
+				return ErrMalformedMigration
+			}
+			b = b[n:]
+			continue
+		case 2:
+			l, n := protoVarint(b)
+			if n == 0 || l > uint64(len(b)-n) {
+				return ErrMalformedMigration
+			}
+			p, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return ErrMalformedMigration
+		}
+		if err := fn(field, v, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decodes a varint from the start of b, returning it and the number of bytes
+// it occupied, or 0 if b does not start with one.
+func protoVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7F) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
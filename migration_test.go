@@ -0,0 +1,149 @@
+package otp
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Appends field to a protocol buffer message, as a varint if v is a uint64 or
+// int, and length-delimited if it is a []byte or string.
+func appendProto(b []byte, field int, v interface{}) []byte {
+	varint := func(b []byte, v uint64) []byte {
+		for v >= 0x80 {
+			b = append(b, byte(v)|0x80)
+			v >>= 7
+		}
+		return append(b, byte(v))
+	}
+	switch v := v.(type) {
+	case int:
+		return varint(varint(b, uint64(field)<<3), uint64(v))
+	case uint64:
+		return varint(varint(b, uint64(field)<<3), v)
+	case string:
+		return append(varint(varint(b, uint64(field)<<3|2), uint64(len(v))), v...)
+	case []byte:
+		return append(varint(varint(b, uint64(field)<<3|2), uint64(len(v))), v...)
+	}
+	panic("unsupported type")
+}
+
+func migrationEntry(secret, name, issuer string, alg, digits, typ int, counter uint64) []byte {
+	var b []byte
+	b = appendProto(b, 1, []byte(secret))
+	b = appendProto(b, 2, name)
+	b = appendProto(b, 3, issuer)
+	b = appendProto(b, 4, alg)
+	b = appendProto(b, 5, digits)
+	b = appendProto(b, 6, typ)
+	return appendProto(b, 7, counter)
+}
+
+func migrationURI(b []byte) string {
+	return "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(b))
+}
+
+func TestParseMigration(t *testing.T) {
+	const sk = "12345678901234567890"
+	var b []byte
+	b = appendProto(b, 1, migrationEntry(sk, "alice@example.com", "Example", 1, 1, 2, 0))
+	b = appendProto(b, 1, migrationEntry(sk, "bob", "", 2, 2, 1, 5))
+	b = appendProto(b, 1, migrationEntry(sk, "carol", "", 4, 1, 2, 0))
+	b = appendProto(b, 1, migrationEntry("1234567890", "dave", "", 1, 1, 2, 0))
+	b = appendProto(b, 1, migrationEntry(sk, "erin", "Default", 0, 0, 2, 0))
+	// version, batch_size, batch_index, and a fixed32 field to be skipped.
+	b = appendProto(b, 2, 1)
+	b = appendProto(b, 3, 1)
+	b = appendProto(b, 4, 0)
+	b = append(b, 6<<3|5, 1, 2, 3, 4)
+
+	keys, err := ParseMigration(migrationURI(b))
+	var merr MigrationError
+	if !errors.As(err, &merr) || len(merr) != 2 {
+		t.Fatalf("Failure: got error %v, want 2 skipped entries", err)
+	}
+	if !errors.Is(merr[0], ErrUnknownHash) || !errors.Is(merr[1], ErrSecretTooShort) {
+		t.Errorf("Failure: unexpected skipped entries: %v", merr)
+	}
+
+	want := []OTPGenerator{
+		TOTPKeyFromBytes([]byte(sk), SHA1, 6, 30, 0),
+		HOTPKeyFromBytes([]byte(sk), SHA256, 8, 5),
+		TOTPKeyFromBytes([]byte(sk), SHA1, 6, 30, 0),
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("Failure: got %d keys, want %d", len(keys), len(want))
+	}
+	for i, k := range keys {
+		switch w := want[i].(type) {
+		case *TOTPKey:
+			if g, ok := k.(*TOTPKey); !ok || *g != *w {
+				t.Errorf("Mismatch on key %d\nWant: %v Got: %v", i, w, k)
+			}
+		case *HOTPKey:
+			if g, ok := k.(*HOTPKey); !ok || g.StringWithSecret() != w.StringWithSecret() {
+				t.Errorf("Mismatch on key %d\nWant: %v Got: %v", i, w, k)
+			}
+		}
+	}
+	// The 20-byte secret of RFC 4226, at counter 5.
+	if otp := keys[1].OTP(); otp != HOTPKeyFromBytes([]byte(sk), SHA256, 8, 5).OTP() {
+		t.Errorf("Failure: migrated key yields %s", otp)
+	}
+
+	// The entries carry each account's name and issuer.
+	entries, err := ParseMigrationEntries(migrationURI(b))
+	if !errors.As(err, &merr) || len(entries) != len(want) {
+		t.Fatalf("Failure: got %d entries (%v)", len(entries), err)
+	}
+	names := []struct{ name, issuer string }{
+		{"alice@example.com", "Example"},
+		{"bob", ""},
+		{"erin", "Default"},
+	}
+	for i, e := range entries {
+		if e.Name != names[i].name || e.Issuer != names[i].issuer || !reflect.DeepEqual(e.Key, keys[i]) {
+			t.Errorf("Mismatch on entry %d\nWant: %q, %q Got: %q, %q", i, names[i].name, names[i].issuer, e.Name, e.Issuer)
+		}
+	}
+
+	// Unpadded, and with unescaped "+"s, as some exporters produce: the secret
+	// is chosen to encode as "+"s.
+	secret := strings.Repeat("\xef\xbe\xfb", 7)[:20]
+	b = appendProto(nil, 1, migrationEntry(secret, "frank", "", 1, 1, 2, 0))
+	d := base64.RawStdEncoding.EncodeToString(b)
+	if !strings.Contains(d, "+") {
+		t.Fatalf("Failure: payload %s has no \"+\"", d)
+	}
+	keys, err = ParseMigration("otpauth-migration://offline?data=" + d)
+	if err != nil || len(keys) != 1 || *keys[0].(*TOTPKey) != *TOTPKeyFromBytes([]byte(secret), SHA1, 6, 30, 0) {
+		t.Errorf("Failure: unpadded payload: got %v (%v)", keys, err)
+	}
+}
+
+func TestParseMigrationMalformed(t *testing.T) {
+	entry := appendProto(nil, 1, migrationEntry("12345678901234567890", "alice", "", 1, 1, 2, 0))
+	w := []string{
+		"otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQ",
+		"otpauth-migration://offline",
+		"otpauth-migration://offline?data=!!!!",
+		migrationURI(entry[:len(entry)-1]),
+		migrationURI([]byte{0x0A, 0x80}),
+		migrationURI([]byte{0x0A, 0x05, 0x01}),
+		migrationURI([]byte{0x0B}),
+		migrationURI([]byte{0x0D, 0x01}),
+		migrationURI([]byte{0x08, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}),
+	}
+	for _, uri := range w {
+		if keys, err := ParseMigration(uri); err == nil || keys != nil {
+			t.Errorf("Failure: %q: got %d keys (%v)", uri, len(keys), err)
+		}
+		if entries, err := ParseMigrationEntries(uri); err == nil || entries != nil {
+			t.Errorf("Failure: %q: got %d entries (%v)", uri, len(entries), err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package otp
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// The time-step of Mobile-OTP, in seconds.
+const motpTimeStep = 10
+
+// The length of Mobile-OTP codes.
+const motpDigits = 6
+
+var (
+	ErrInvalidMOTPKey = errors.New("otp: invalid MOTPKey")
+	// Returned by ValidateDetailed for a MOTPKey without a Secret or PIN.
+	// They wrap ErrInvalidMOTPKey.
+	ErrNoMOTPSecret = fmt.Errorf("%w: secret is empty", ErrInvalidMOTPKey)
+	ErrNoMOTPPIN    = fmt.Errorf("%w: PIN is empty", ErrInvalidMOTPKey)
+)
+
+// Represents a Mobile-OTP (mOTP) parameter-set. Unlike HOTP and TOTP, mOTP is
+// not HMAC-based: an OTP is the first 6 hexadecimal digits of the MD5 of the
+// number of 10-second steps since the Unix epoch, the secret, and the PIN, all
+// concatenated as strings. Secret is conventionally 16 hexadecimal digits.
+type MOTPKey struct {
+	Secret string `json:"secret"`
+	PIN    string `json:"pin"`
+}
+
+// Computes and returns an OTP using the mOTP parameter-set. If the receiver
+// MOTPKey is invalid, OnInvalidKey is called.
+func (k *MOTPKey) OTP() string {
+	return k.OTPAt(time.Now())
+}
+
+// Computes and returns the OTP for time t using the mOTP parameter-set. If the
+// receiver MOTPKey is invalid, OnInvalidKey is called.
+func (k *MOTPKey) OTPAt(t time.Time) string {
+	if err := k.ValidateDetailed(); err != nil {
+		invalidKey(err)
+		return ""
+	}
+	return k.otp(t.Unix() / motpTimeStep)
+}
+
+func (k *MOTPKey) otp(step int64) string {
+	sum := md5.Sum([]byte(strconv.FormatInt(step, 10) + k.Secret + k.PIN))
+	return hex.EncodeToString(sum[:])[:motpDigits]
+}
+
+// The widest window of MOTPKey.Verify, in time-steps either side of the
+// current one: an hour's worth.
+const MaxMOTPWindow = 3600 / motpTimeStep
+
+// Verifies code against the mOTP parameter-set, accepting codes from up to
+// window time-steps before or after the current one. The window is clamped to
+// MaxMOTPWindow time-steps. The comparison is constant-time. If the receiver
+// MOTPKey or code is malformed, false is returned.
+func (k *MOTPKey) Verify(code string, window uint) bool {
+	return k.verifyAt(code, time.Now(), window)
+}
+
+func (k *MOTPKey) verifyAt(code string, t time.Time, window uint) bool {
+	code = trimCode(code)
+	if !k.Validate() || len(code) != motpDigits {
+		return false
+	}
+	if window > MaxMOTPWindow {
+		window = MaxMOTPWindow
+	}
+	step := t.Unix() / motpTimeStep
+	for i := -int64(window); i <= int64(window); i++ {
+		if Equal(k.otp(step+i), code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validates a MOTPKey.
+func (k *MOTPKey) Validate() bool {
+	return k.ValidateDetailed() == nil
+}
+
+// Validates a MOTPKey, returning an error describing why it is invalid, if it
+// is: ErrNoMOTPSecret or ErrNoMOTPPIN.
+func (k *MOTPKey) ValidateDetailed() error {
+	if k.Secret == "" {
+		return ErrNoMOTPSecret
+	}
+	if k.PIN == "" {
+		return ErrNoMOTPPIN
+	}
+	return nil
+}
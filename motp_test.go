@@ -0,0 +1,63 @@
+package otp
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMOTP(t *testing.T) {
+	k := MOTPKey{Secret: "0123456789abcdef", PIN: "1234"}
+	// Computed with the reference algorithm, md5(epoch/10 + secret + PIN).
+	w := []struct {
+		at     int64
+		expect string
+	}{
+		{0, "41e571"},
+		{59, "3982c0"},
+		{1111111109, "063dcf"},
+		{1234567890, "f41e13"},
+		{2000000000, "b0c2e7"},
+	}
+	for _, v := range w {
+		if otp := k.OTPAt(time.Unix(v.at, 0)); otp != v.expect {
+			t.Errorf("Mismatch at time %d:\nWant: %s Got: %s", v.at, v.expect, otp)
+		}
+	}
+
+	at := time.Unix(1234567890, 0)
+	prev, next := k.OTPAt(at.Add(-10*time.Second)), k.OTPAt(at.Add(10*time.Second))
+	if !k.verifyAt("f41e13", at, 0) {
+		t.Errorf("Failure: current code rejected")
+	}
+	if k.verifyAt(prev, at, 0) || k.verifyAt(next, at, 0) {
+		t.Errorf("Failure: adjacent code accepted with window 0")
+	}
+	if !k.verifyAt(prev, at, 1) || !k.verifyAt(next, at, 1) {
+		t.Errorf("Failure: adjacent code rejected with window 1")
+	}
+	if k.verifyAt("f41e1", at, 1) || k.verifyAt("", at, 1) {
+		t.Errorf("Failure: malformed code accepted")
+	}
+	if !k.Verify(k.OTP(), 1) {
+		t.Errorf("Failure: current code rejected")
+	}
+
+	// Wide windows are clamped, rather than searched for ever.
+	edge := k.OTPAt(at.Add(MaxMOTPWindow * motpTimeStep * time.Second))
+	if !k.verifyAt(edge, at, math.MaxUint) || !k.verifyAt(edge, at, MaxMOTPWindow) {
+		t.Errorf("Failure: code at the widest window rejected")
+	}
+	if k.verifyAt(edge, at, MaxMOTPWindow-1) {
+		t.Errorf("Failure: code beyond the window accepted")
+	}
+	beyond := k.OTPAt(at.Add(-(MaxMOTPWindow + 1) * motpTimeStep * time.Second))
+	if k.verifyAt(beyond, at, math.MaxUint) {
+		t.Errorf("Failure: code beyond the widest window accepted")
+	}
+
+	invalid := MOTPKey{Secret: "0123456789abcdef"}
+	if invalid.Validate() || invalid.Verify("f41e13", 1) {
+		t.Errorf("Failure: key without a PIN accepted")
+	}
+}
@@ -0,0 +1,225 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Represents an OCRA (RFC 6287) parameter-set. SecretKey is encoded as in
+// HOTPKey, and Suite is an OCRA suite, such as "OCRA-1:HOTP-SHA1-6:QN08".
+type OCRA struct {
+	SecretKey      string         `json:"secret_key"`
+	SecretEncoding SecretEncoding `json:"secret_encoding,omitempty"`
+	Suite          string         `json:"suite"`
+}
+
+// Represents a parsed OCRA suite.
+type OCRASuite struct {
+	HashFunction HashFunction
+	Digits       byte
+	// Whether a counter is part of the data input.
+	Counter bool
+	// The challenge format, 'A' (alphanumeric), 'N' (numeric), or 'H'
+	// (hexadecimal), and its maximum length.
+	ChallengeFormat byte
+	ChallengeLen    int
+	// The hash function of the password, if one is part of the data input.
+	PasswordHash HashFunction
+	// The length of the session information, if it is part of the data input.
+	SessionLen int
+	// The time-step in seconds, if a timestamp is part of the data input.
+	TimeStep uint64
+}
+
+// Holds the optional parts of an OCRA data input. Only the parts that the suite
+// calls for are used.
+type OCRAInput struct {
+	Counter uint64
+	// The password, which is hashed as the suite specifies.
+	Password string
+	// The session information, left-padded with zeros to the suite's length.
+	Session []byte
+	Time    time.Time
+}
+
+// The size of the challenge field of the data input.
+const ocraChallengeSize = 128
+
+var ErrInvalidOCRASuite = errors.New("otp: invalid OCRA suite")
+
+// Parses an OCRA suite of the form "OCRA-1:HOTP-<hash>-<digits>:<data input>",
+// per RFC 6287 section 6. Truncation to 0 digits is not supported.
+func ParseSuite(suite string) (*OCRASuite, error) {
+	parts := strings.Split(suite, ":")
+	if len(parts) != 3 || parts[0] != "OCRA-1" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidOCRASuite, suite)
+	}
+	cf := strings.Split(parts[1], "-")
+	if len(cf) != 3 || cf[0] != "HOTP" {
+		return nil, fmt.Errorf("%w: crypto function %q", ErrInvalidOCRASuite, parts[1])
+	}
+	var s OCRASuite
+	var ok bool
+	if s.HashFunction, ok = ocraHash(cf[1]); !ok {
+		return nil, fmt.Errorf("%w: hash function %q", ErrInvalidOCRASuite, cf[1])
+	}
+	d, err := strconv.ParseUint(cf[2], 10, 8)
+	if err != nil || d < 4 || d > MaxDigits {
+		return nil, fmt.Errorf("%w: digits %q", ErrInvalidOCRASuite, cf[2])
+	}
+	s.Digits = byte(d)
+
+	di := strings.Split(parts[2], "-")
+	if di[0] == "C" {
+		s.Counter = true
+		di = di[1:]
+	}
+	if len(di) == 0 || len(di[0]) != 4 || di[0][0] != 'Q' ||
+		!strings.ContainsRune("ANH", rune(di[0][1])) {
+		return nil, fmt.Errorf("%w: data input %q", ErrInvalidOCRASuite, parts[2])
+	}
+	s.ChallengeFormat = di[0][1]
+	s.ChallengeLen, err = strconv.Atoi(di[0][2:])
+	if err != nil || s.ChallengeLen < 4 || s.ChallengeLen > 64 {
+		return nil, fmt.Errorf("%w: challenge %q", ErrInvalidOCRASuite, di[0])
+	}
+	for _, v := range di[1:] {
+		switch {
+		case strings.HasPrefix(v, "P") && s.PasswordHash == "" &&
+			s.SessionLen == 0 && s.TimeStep == 0:
+			if s.PasswordHash, ok = ocraHash(v[1:]); !ok {
+				return nil, fmt.Errorf("%w: password %q", ErrInvalidOCRASuite, v)
+			}
+		case strings.HasPrefix(v, "S") && s.SessionLen == 0 && s.TimeStep == 0:
+			s.SessionLen, err = strconv.Atoi(v[1:])
+			if err != nil || len(v) != 4 || s.SessionLen <= 0 {
+				return nil, fmt.Errorf("%w: session information %q", ErrInvalidOCRASuite, v)
+			}
+		case strings.HasPrefix(v, "T") && s.TimeStep == 0:
+			if s.TimeStep, ok = ocraTimeStep(v[1:]); !ok {
+				return nil, fmt.Errorf("%w: timestamp %q", ErrInvalidOCRASuite, v)
+			}
+		default:
+			return nil, fmt.Errorf("%w: data input %q", ErrInvalidOCRASuite, v)
+		}
+	}
+	return &s, nil
+}
+
+// Maps the hash function names of OCRA suites to HashFunction.
+func ocraHash(name string) (HashFunction, bool) {
+	switch HashFunction(name) {
+	case SHA1, SHA256, SHA512:
+		return HashFunction(name), true
+	}
+	return "", false
+}
+
+// Parses the time-step of an OCRA timestamp, such as "30S", "1M", or "1H".
+func ocraTimeStep(g string) (uint64, bool) {
+	if len(g) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(g[:len(g)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch g[len(g)-1] {
+	case 'S':
+		return n, n >= 1 && n <= 59
+	case 'M':
+		return n * 60, n >= 1 && n <= 59
+	case 'H':
+		return n * 3600, n >= 1 && n <= 48
+	}
+	return 0, false
+}
+
+// Computes and returns the OCRA response to challenge, with the other parts of
+// the data input taken from in.
+func (o *OCRA) Compute(challenge string, in OCRAInput) (string, error) {
+	s, err := ParseSuite(o.Suite)
+	if err != nil {
+		return "", err
+	}
+	sk, err := decodeSecret(o.SecretKey, o.SecretEncoding)
+	if err != nil || len(sk) < MinKeySize {
+		return "", fmt.Errorf("otp: invalid OCRA secret-key")
+	}
+	q, err := s.challenge(challenge)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(lookupHash(s.HashFunction), sk)
+	mac.Write([]byte(o.Suite))
+	mac.Write([]byte{0})
+	var buf [8]byte
+	if s.Counter {
+		binary.BigEndian.PutUint64(buf[:], in.Counter)
+		mac.Write(buf[:])
+	}
+	mac.Write(q)
+	if s.PasswordHash != "" {
+		h := lookupHash(s.PasswordHash)()
+		h.Write([]byte(in.Password))
+		mac.Write(h.Sum(nil))
+	}
+	if s.SessionLen > 0 {
+		if len(in.Session) > s.SessionLen {
+			return "", fmt.Errorf("otp: session information exceeds %d bytes", s.SessionLen)
+		}
+		mac.Write(make([]byte, s.SessionLen-len(in.Session)))
+		mac.Write(in.Session)
+	}
+	if s.TimeStep > 0 {
+		binary.BigEndian.PutUint64(buf[:], uint64(in.Time.Unix())/s.TimeStep)
+		mac.Write(buf[:])
+	}
+	b, err := dynamicTruncation(mac.Sum(nil))
+	if err != nil {
+		return "", err
+	}
+	return formatDecimal(b, s.Digits), nil
+}
+
+// Encodes challenge into the challenge field of the data input, per the
+// reference implementation of RFC 6287: numeric challenges are converted to
+// hexadecimal, and the field is padded on the right with zeros.
+func (s *OCRASuite) challenge(challenge string) ([]byte, error) {
+	if challenge == "" || len(challenge) > s.ChallengeLen {
+		return nil, fmt.Errorf("otp: challenge length must be between 1 and %d", s.ChallengeLen)
+	}
+	var q []byte
+	switch s.ChallengeFormat {
+	case 'A':
+		q = []byte(challenge)
+	case 'N', 'H':
+		hs := challenge
+		if s.ChallengeFormat == 'N' {
+			n, ok := new(big.Int).SetString(challenge, 10)
+			if !ok || n.Sign() < 0 {
+				return nil, fmt.Errorf("otp: malformed numeric challenge %q", challenge)
+			}
+			hs = n.Text(16)
+		}
+		if len(hs)%2 != 0 {
+			hs += "0"
+		}
+		var err error
+		if q, err = hex.DecodeString(hs); err != nil {
+			return nil, fmt.Errorf("otp: malformed hexadecimal challenge %q", challenge)
+		}
+	}
+	if len(q) > ocraChallengeSize {
+		return nil, fmt.Errorf("otp: challenge exceeds %d bytes", ocraChallengeSize)
+	}
+	return append(q, make([]byte, ocraChallengeSize-len(q))...), nil
+}
@@ -0,0 +1,81 @@
+package otp
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6287 appendix C.
+func TestOCRA(t *testing.T) {
+	const (
+		seed20 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+		seed32 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA===="
+		seed64 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA="
+	)
+	w := []struct {
+		OCRA
+		challenge string
+		in        OCRAInput
+		expect    string
+	}{
+		{OCRA{SecretKey: seed20, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}, "00000000", OCRAInput{}, "237653"},
+		{OCRA{SecretKey: seed20, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}, "11111111", OCRAInput{}, "243178"},
+		{OCRA{SecretKey: seed20, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}, "22222222", OCRAInput{}, "653583"},
+		{OCRA{SecretKey: seed20, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}, "33333333", OCRAInput{}, "740991"},
+		{OCRA{SecretKey: seed20, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}, "44444444", OCRAInput{}, "608993"},
+		{OCRA{SecretKey: seed20, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}, "55555555", OCRAInput{}, "388898"},
+		{OCRA{SecretKey: seed20, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}, "66666666", OCRAInput{}, "816933"},
+		{OCRA{SecretKey: seed20, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}, "77777777", OCRAInput{}, "224598"},
+		{OCRA{SecretKey: seed20, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}, "88888888", OCRAInput{}, "750600"},
+		{OCRA{SecretKey: seed20, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}, "99999999", OCRAInput{}, "294470"},
+		{OCRA{SecretKey: seed32, Suite: "OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1"}, "12345678", OCRAInput{Counter: 0, Password: "1234"}, "65347737"},
+		{OCRA{SecretKey: seed32, Suite: "OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1"}, "12345678", OCRAInput{Counter: 9, Password: "1234"}, "08522129"},
+		{OCRA{SecretKey: seed32, Suite: "OCRA-1:HOTP-SHA256-8:QN08-PSHA1"}, "00000000", OCRAInput{Password: "1234"}, "83238735"},
+		{OCRA{SecretKey: seed32, Suite: "OCRA-1:HOTP-SHA256-8:QN08-PSHA1"}, "44444444", OCRAInput{Password: "1234"}, "86807031"},
+		{OCRA{SecretKey: seed64, Suite: "OCRA-1:HOTP-SHA512-8:C-QN08"}, "00000000", OCRAInput{Counter: 0}, "07016083"},
+		{OCRA{SecretKey: seed64, Suite: "OCRA-1:HOTP-SHA512-8:QN08-T1M"}, "00000000", OCRAInput{Time: time.Unix(0x132d0b6*60, 0)}, "95209754"},
+		{OCRA{SecretKey: seed64, Suite: "OCRA-1:HOTP-SHA512-8:QN08-T1M"}, "44444444", OCRAInput{Time: time.Unix(0x132d0b6*60, 0)}, "36209546"},
+	}
+	for _, v := range w {
+		res, err := v.Compute(v.challenge, v.in)
+		if err != nil {
+			t.Errorf("Failure on suite %s: %v", v.Suite, err)
+		} else if res != v.expect {
+			t.Errorf("Mismatch on suite %s, challenge %s:\nWant: %s Got: %s", v.Suite, v.challenge, v.expect, res)
+		}
+	}
+}
+
+func TestParseSuite(t *testing.T) {
+	s, err := ParseSuite("OCRA-1:HOTP-SHA512-8:C-QH64-PSHA256-S128-T30S")
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	want := OCRASuite{SHA512, 8, true, 'H', 64, SHA256, 128, 30}
+	if *s != want {
+		t.Errorf("Mismatch:\nWant: %+v Got: %+v", want, *s)
+	}
+
+	invalid := []string{
+		"",
+		"OCRA-2:HOTP-SHA1-6:QN08",
+		"OCRA-1:TOTP-SHA1-6:QN08",
+		"OCRA-1:HOTP-MD5-6:QN08",
+		"OCRA-1:HOTP-SHA1-0:QN08",
+		"OCRA-1:HOTP-SHA1-11:QN08",
+		"OCRA-1:HOTP-SHA1-6:C",
+		"OCRA-1:HOTP-SHA1-6:QX08",
+		"OCRA-1:HOTP-SHA1-6:QN65",
+		"OCRA-1:HOTP-SHA1-6:QN08-C",
+		"OCRA-1:HOTP-SHA1-6:QN08-T1M-PSHA1",
+		"OCRA-1:HOTP-SHA1-6:QN08-S64",
+		"OCRA-1:HOTP-SHA1-6:QN08-T60S",
+		"OCRA-1:HOTP-SHA1-6:QN08-T49H",
+		"OCRA-1:HOTP-SHA1-6:QN08-T0H",
+	}
+	for _, v := range invalid {
+		if _, err := ParseSuite(v); err == nil {
+			t.Errorf("Failure: invalid suite accepted: %q", v)
+		}
+	}
+}
@@ -1,115 +1,728 @@
 // Package 'otp' is an easy-to-use implementation of RFC 4226 (HOTP) and RFC
 // 6238 (TOTP).
+//
+// Keys are best constructed with NewHOTPKey and NewTOTPKeyFrom, which validate
+// them, so that their OTP methods cannot panic.
+//
+// All functions and methods are safe for concurrent use, provided that a key is
+// not modified while it is being used from other goroutines.
 package otp
 
 import (
 	"crypto/hmac"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"hash"
-	"strconv"
+	"math"
 	"time"
 )
 
+// Names a hash function for the HMAC. The empty HashFunction is taken to be
+// DefaultHash, SHA1, for compatibility with Google Authenticator.
 type HashFunction string
 
 const (
 	SHA1   HashFunction = "SHA1"
 	SHA256 HashFunction = "SHA256"
 	SHA512 HashFunction = "SHA512"
+	// SHA-512/256, for platforms that require it. Few authenticators support
+	// it.
+	SHA512_256 HashFunction = "SHA512_256"
 
+	// The shortest secret-key, in bytes, that Validate accepts, as RFC 4226
+	// requires, unless overridden with SetAllowShortSecret. RFC 6238
+	// recommends longer ones for SHA256 and SHA512; see MinKeySizeFor.
 	MinKeySize = 16
-	MaxDigits  = 10
+
+	// Dynamic truncation yields a 31-bit value, at most 2147483647, of which
+	// an OTP is the last Digits decimal digits. Codes of 10 digits therefore
+	// hold no more than about 9.3 digits' worth of entropy: their leading
+	// digit is always 0, 1, or 2. EntropyWarning flags such keys.
+	MaxDigits = 10
+
+	// The digest size of SHA1, the shortest for which dynamic truncation is
+	// defined.
+	minDigestSize = 20
+
+	// The largest offset obtainable through dynamic truncation.
+	maxTruncationOffset = 15
+)
+
+// Default parameters, per the de-facto Key Uri Format followed by Google
+// Authenticator and most other authenticators.
+const (
+	DefaultDigits     = 6
+	DefaultTimeStep   = 30
+	DefaultHash       = SHA1
+	DefaultSecretSize = 20
+)
+
+var (
+	ErrInvalidHOTPKey = errors.New("otp: invalid HOTPKey")
+	ErrInvalidTOTPKey = errors.New("otp: invalid TOTPKey")
+	ErrShortDigest    = errors.New("otp: digest too short for dynamic truncation")
+	ErrBeforeT0       = errors.New("otp: time precedes T0")
+
+	ErrBadBase32        = errors.New("otp: secret-key is not valid base-32")
+	ErrBadBase16        = errors.New("otp: secret-key is not valid hexadecimal")
+	ErrUnknownEncoding  = errors.New("otp: unknown secret encoding")
+	ErrSecretTooShort   = errors.New("otp: secret-key is shorter than MinKeySize")
+	ErrUnknownHash      = errors.New("otp: unknown hash function")
+	ErrDigitsOutOfRange = errors.New("otp: digits out of range")
+	ErrOffsetOutOfRange = errors.New("otp: truncation offset out of range")
+	ErrInvalidTimeStep  = errors.New("otp: time-step must be positive")
+	ErrT0InFuture       = errors.New("otp: T0 is in the future")
+	ErrStepNotSeconds   = errors.New("otp: time-step must be a whole number of seconds")
+	ErrUnknownOutput    = errors.New("otp: unknown output encoding")
+	ErrClockOffset      = errors.New("otp: clock offset exceeds MaxClockOffset")
+
+	// Wraps ErrDigitsOutOfRange, for the common mistake of leaving Digits
+	// unset.
+	ErrZeroDigits = fmt.Errorf("%w: OTPs must have at least 1 digit", ErrDigitsOutOfRange)
 )
 
-var hfMap map[HashFunction]func() hash.Hash
+// Called with the error encountered by the methods that cannot return one,
+// such as OTP, which is typically that their key is invalid. If it returns, so
+// do they, with a zero value, such as an empty OTP. It defaults to panicking,
+// which, unlike exiting, runs deferred calls and can be recovered from.
+// Replacing it, such as to log the error instead, trades crashes for zero
+// values that callers must then detect; preferably, use the variants that
+// return errors, such as Generate. It must not be changed while keys are in
+// use.
+var OnInvalidKey = func(err error) {
+	panic(err)
+}
 
-func init() {
-	hfMap = make(map[HashFunction]func() hash.Hash)
-	hfMap[SHA1] = sha1.New
-	hfMap[SHA256] = sha256.New
-	hfMap[SHA512] = sha512.New
+func invalidKey(err error) {
+	if OnInvalidKey == nil {
+		panic(err)
+	}
+	OnInvalidKey(err)
 }
 
-// Represents an HOTP parameter-set. SecretKey must be base-32 encoded.
+// Implemented by both *HOTPKey and *TOTPKey, for handling either kind of key.
+type OTPGenerator interface {
+	OTP() string
+	Generate() (string, error)
+	Validate() bool
+}
+
+var (
+	_ OTPGenerator = (*HOTPKey)(nil)
+	_ OTPGenerator = (*TOTPKey)(nil)
+)
+
+// Represents an HOTP parameter-set. SecretKey must be encoded as specified by
+// SecretEncoding, which defaults to base-32. For base-32 encodings, the padding
+// may be omitted, and case, whitespace, and hyphens are ignored.
 type HOTPKey struct {
-	SecretKey    string       `json:"secret_key"`
-	HashFunction HashFunction `json:"hash_function"`
-	Digits       byte         `json:"digits"`
-	Counter      uint64       `json:"counter"`
+	SecretKey      string         `json:"secret_key"`
+	SecretEncoding SecretEncoding `json:"secret_encoding,omitempty"`
+	HashFunction   HashFunction   `json:"hash_function"`
+	Digits         byte           `json:"digits"`
+	Counter        uint64         `json:"counter"`
+	// If non-nil, the fixed offset, from 0 to 15, at which the HMAC is
+	// truncated, in place of RFC 4226's dynamic truncation. This is for
+	// interoperability with legacy tokens only.
+	TruncationOffset *int `json:"truncation_offset,omitempty"`
+	// How the truncated value is formatted into OTPs; see OutputEncoding.
+	Encoding OutputEncoding `json:"encoding,omitempty"`
+	// If non-empty, prepended to the counter in the HMAC's message, so that
+	// keys sharing a secret-key but differing in Domain, such as one for
+	// logins and one for signing transactions, yield unrelated OTPs. Standard
+	// authenticators know nothing of it, so their OTPs only match keys with
+	// the empty Domain.
+	Domain string `json:"domain,omitempty"`
+
+	// If non-nil, the base-32 encoding of SecretKey; see SetBase32Encoding.
+	alphabet *base32.Encoding
+	// If non-nil, the hash function, in place of HashFunction; see SetHash.
+	hashFn *hashFactory
+	// Whether secret-keys shorter than MinKeySize are allowed; see
+	// SetAllowShortSecret.
+	allowShort bool
 }
 
+// Specifies how the value from which an OTP is formatted is rendered.
+type OutputEncoding byte
+
+const (
+	// The last Digits decimal digits of the value, as specified by RFC 4226.
+	Decimal OutputEncoding = iota
+	// The last Digits lowercase hexadecimal digits of the value, for
+	// proprietary systems that expect them. Digits may be at most 8.
+	Hex
+)
+
+// The most hexadecimal digits an OTP may have: there are only 32 bits in the
+// truncated value.
+const maxHexDigits = 8
+
 // Computes and returns an OTP using the HOTP parameter-set. If the receiver
-// HOTPKey is invalid, the program panics.
+// HOTPKey is invalid, OnInvalidKey is called.
 func (k *HOTPKey) OTP() string {
-	if !k.Validate() {
-		panic("invalid HOTPKey")
-	}
-	ctri := k.Counter
-	var ctr [8]byte
-	for i := len(ctr) - 1; i >= 0; i-- {
-		ctr[i] = byte(ctri & 0xFF)
-		ctri >>= 8
-	}
-	sk, _ := base32.StdEncoding.DecodeString(k.SecretKey)
-	mac := hmac.New(hfMap[k.HashFunction], sk)
-	mac.Write(ctr[:])
-	mres := mac.Sum(nil)
-	i := mres[len(mres)-1] & 0x0F
-	b := int(mres[i])<<24 | int(mres[i+1])<<16 |
-		int(mres[i+2])<<8 | int(mres[i+3])
-	b &= 0x7FFFFFFF
-	res := ""
-	for i := 0; i < int(k.Digits); i++ {
-		res = strconv.FormatInt(int64(b%10), 10) + res
-		b /= 10
-	}
-	return res
+	otp, err := k.Generate()
+	if err != nil {
+		invalidKey(err)
+		return ""
+	}
+	return otp
+}
+
+// Computes and returns the OTP for the given counter, rather than Counter,
+// using the HOTP parameter-set; the receiver HOTPKey is not modified. If it is
+// invalid, OnInvalidKey is called.
+func (k *HOTPKey) OTPAt(counter uint64) string {
+	g, err := k.generator()
+	if err != nil {
+		invalidKey(err)
+		return ""
+	}
+	otp, err := g.generate(counter)
+	if err != nil {
+		invalidKey(err)
+		return ""
+	}
+	return otp
+}
+
+// Computes and returns an OTP using the HOTP parameter-set. Unlike OTP, an
+// error is returned rather than panicking; if the receiver HOTPKey is invalid,
+// it is the error of ValidateDetailed.
+func (k *HOTPKey) Generate() (string, error) {
+	g, err := k.generator()
+	if err != nil {
+		return "", err
+	}
+	return g.generate(k.Counter)
+}
+
+// Formats the last digits decimal digits of b, zero-padded.
+func formatDecimal(b uint32, digits byte) string {
+	return fmt.Sprintf("%0*d", digits, uint64(b)%pow10(digits))
+}
+
+// Formats the last digits hexadecimal digits of b, zero-padded.
+func formatHex(b uint32, digits byte) string {
+	return fmt.Sprintf("%0*x", digits, uint64(b)&(1<<(4*uint(digits))-1))
+}
+
+// Returns 10 to the power of n.
+func pow10(n byte) uint64 {
+	p := uint64(1)
+	for i := byte(0); i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// Computes and returns the dynamic truncation of the HMAC of the counter: the
+// 31-bit value from which OTPs are formatted, by taking its last Digits
+// decimal digits. This allows for custom encodings of OTPs.
+func (k *HOTPKey) Value() (uint32, error) {
+	g, err := k.generator()
+	if err != nil {
+		return 0, err
+	}
+	return g.value(k.Counter)
+}
+
+// Computes the OTPs of an HOTP parameter-set for arbitrary counters, reusing a
+// single HMAC: setting one up costs about as much as computing an OTP, which
+// adds up when searching many counters. It is not safe for concurrent use.
+type hotpGenerator struct {
+	k   *HOTPKey
+	mac hash.Hash
+	sum []byte
+}
+
+// Returns a generator for the parameter-set of the HOTPKey, ignoring its
+// counter, or the error of ValidateDetailed if the HOTPKey is invalid.
+func (k *HOTPKey) generator() (*hotpGenerator, error) {
+	sk, err := k.validate()
+	if err != nil {
+		return nil, err
+	}
+	return &hotpGenerator{k: k, mac: hmac.New(k.hashFunc(), sk)}, nil
+}
+
+// Like HOTPKey.Value, for the given counter.
+func (g *hotpGenerator) value(counter uint64) (uint32, error) {
+	ctr := counterBytes(counter)
+	g.mac.Reset()
+	g.mac.Write([]byte(g.k.Domain))
+	g.mac.Write(ctr[:])
+	g.sum = g.mac.Sum(g.sum[:0])
+	if g.k.TruncationOffset != nil {
+		return truncation(g.sum, *g.k.TruncationOffset)
+	}
+	return dynamicTruncation(g.sum)
+}
+
+// Returns the moving factor for counter, as the 8-byte, big-endian message of
+// the HMAC, per RFC 4226 section 5.2.
+func counterBytes(counter uint64) [8]byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], counter)
+	return b
+}
+
+// Like HOTPKey.Generate, for the given counter.
+func (g *hotpGenerator) generate(counter uint64) (string, error) {
+	b, err := g.value(counter)
+	if err != nil {
+		return "", err
+	}
+	if g.k.Encoding == Hex {
+		return formatHex(b, g.k.Digits), nil
+	}
+	return formatDecimal(b, g.k.Digits), nil
+}
+
+// Performs the dynamic truncation of RFC 4226 section 5.3 on an HMAC.
+func dynamicTruncation(mres []byte) (uint32, error) {
+	return truncation(mres, int(mres[len(mres)-1]&0x0F))
+}
+
+// Truncates an HMAC to the 31 bits at offset i.
+func truncation(mres []byte, i int) (uint32, error) {
+	// The offset may be up to 15, and 4 bytes are read from it.
+	if len(mres) < minDigestSize {
+		return 0, ErrShortDigest
+	}
+	b := uint32(mres[i])<<24 | uint32(mres[i+1])<<16 |
+		uint32(mres[i+2])<<8 | uint32(mres[i+3])
+	return b & 0x7FFFFFFF, nil
 }
 
 // Validates an HOTPKey.
 func (k *HOTPKey) Validate() bool {
-	sk, err := base32.StdEncoding.DecodeString(k.SecretKey)
-	return len(sk) >= MinKeySize && hfMap[k.HashFunction] != nil &&
-		k.Digits <= MaxDigits && k.Digits > 0 && err == nil
+	return k.ValidateDetailed() == nil
+}
+
+// Validates an HOTPKey, returning an error describing why it is invalid, if
+// it is: ErrBadBase32 (or ErrMalformedSecret or ErrNonCanonicalSecret, which
+// wrap it), ErrBadBase16, ErrUnknownEncoding, ErrSecretTooShort,
+// ErrUnknownHash, ErrZeroDigits, ErrDigitsOutOfRange, ErrUnknownOutput, or
+// ErrOffsetOutOfRange.
+func (k *HOTPKey) ValidateDetailed() error {
+	_, err := k.validate()
+	return err
+}
+
+// Validates an HOTPKey, returning its decoded secret-key if it is valid, so
+// that generation decodes it only once.
+func (k *HOTPKey) validate() ([]byte, error) {
+	sk, err := k.secret()
+	if err != nil {
+		return nil, err
+	}
+	if len(sk) < MinKeySize && !(k.allowShort && len(sk) > 0) {
+		return nil, ErrSecretTooShort
+	}
+	if k.hashFunc() == nil {
+		return nil, ErrUnknownHash
+	}
+	if k.Digits == 0 {
+		return nil, ErrZeroDigits
+	}
+	switch k.Encoding {
+	case Decimal:
+		if k.Digits > MaxDigits {
+			return nil, ErrDigitsOutOfRange
+		}
+	case Hex:
+		if k.Digits > maxHexDigits {
+			return nil, ErrDigitsOutOfRange
+		}
+	default:
+		return nil, ErrUnknownOutput
+	}
+	if o := k.TruncationOffset; o != nil && (*o < 0 || *o > maxTruncationOffset) {
+		return nil, ErrOffsetOutOfRange
+	}
+	return sk, nil
+}
+
+// Returns a deep copy of the HOTPKey, which shares no memory with the receiver.
+func (k *HOTPKey) Clone() *HOTPKey {
+	c := *k
+	if k.TruncationOffset != nil {
+		o := *k.TruncationOffset
+		c.TruncationOffset = &o
+	}
+	return &c
+}
+
+// Returned by AsTOTP for HOTPKeys with a fixed TruncationOffset or a
+// non-decimal Encoding, which TOTPKeys do not support.
+var ErrNoTOTPEquivalent = errors.New("otp: HOTPKey has no TOTP equivalent")
+
+// Returns a TOTPKey with the secret-key, hash function, digits, and Domain of
+// the receiver HOTPKey, and the given time-step and T0, such as to switch an
+// account from HOTP to TOTP. The TOTPKey's OTP for a time is the HOTPKey's for
+// the counter of that time-step. The error of ValidateDetailed is returned if
+// the TOTPKey is invalid, and ErrNoTOTPEquivalent if the HOTPKey uses a
+// feature TOTPKeys lack.
+func (k *HOTPKey) AsTOTP(timeStep, t0 uint64) (*TOTPKey, error) {
+	if k.TruncationOffset != nil || k.Encoding != Decimal {
+		return nil, ErrNoTOTPEquivalent
+	}
+	t := &TOTPKey{
+		SecretKey:      k.SecretKey,
+		SecretEncoding: k.SecretEncoding,
+		HashFunction:   k.HashFunction,
+		Digits:         k.Digits,
+		TimeStep:       timeStep,
+		T0:             t0,
+		Domain:         k.Domain,
+		alphabet:       k.alphabet,
+		hashFn:         k.hashFn,
+		allowShort:     k.allowShort,
+	}
+	if err := t.ValidateDetailed(); err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
-// Represents a TOTP parameter-set. Like in HOTPKey, SecretKey must be base-32
-// encoded. Even though T0 not a parameter in virtually all implementations,
-// according to RFC 6238, it is not necessarily always 0—which is why it is a
-// parameter here.
+// Reports whether other has the same parameter-set as the receiver HOTPKey,
+// ignoring Counter, such as to find duplicates among imported keys. The
+// secret-keys are compared decoded, in constant time, so that the same
+// secret-key in different encodings matches. If either secret-key cannot be
+// decoded, false is returned.
+func (k *HOTPKey) SameParams(other *HOTPKey) bool {
+	if !sameSecret(k, other) || !sameHash(k.HashFunction, other.HashFunction) ||
+		k.Digits != other.Digits || k.Encoding != other.Encoding ||
+		k.Domain != other.Domain {
+		return false
+	}
+	if k.TruncationOffset == nil || other.TruncationOffset == nil {
+		return k.TruncationOffset == other.TruncationOffset
+	}
+	return *k.TruncationOffset == *other.TruncationOffset
+}
+
+func sameSecret(a, b *HOTPKey) bool {
+	x, err := a.secret()
+	if err != nil {
+		return false
+	}
+	y, err := b.secret()
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(x, y) == 1
+}
+
+// Reports whether a and b name the same hash function, taking the empty name
+// to be DefaultHash and matching case-insensitively, as JSON does.
+func sameHash(a, b HashFunction) bool {
+	if a == "" {
+		a = DefaultHash
+	}
+	if b == "" {
+		b = DefaultHash
+	}
+	return canonicalHash(a) == canonicalHash(b)
+}
+
+// Represents a TOTP parameter-set. Like in HOTPKey, SecretKey must be encoded
+// as specified by SecretEncoding. Even though T0 not a parameter in virtually
+// all implementations, according to RFC 6238, it is not necessarily always
+// 0—which is why it is a parameter here. TimeStep and T0 are in seconds;
+// sub-second time-steps are not supported.
 type TOTPKey struct {
-	SecretKey    string       `json:"secret_key"`
-	HashFunction HashFunction `json:"hash_function"`
-	Digits       byte         `json:"digits"`
-	TimeStep     uint64       `json:"time_step"`
-	T0           uint64       `json:"t0"`
+	SecretKey      string         `json:"secret_key"`
+	SecretEncoding SecretEncoding `json:"secret_encoding,omitempty"`
+	HashFunction   HashFunction   `json:"hash_function"`
+	Digits         byte           `json:"digits"`
+	TimeStep       uint64         `json:"time_step"`
+	T0             uint64         `json:"t0"`
+	// How far the token's clock runs ahead of the true time, or behind it if
+	// negative, as measured with EstimateDrift: an offset of n time-steps is
+	// n*TimeStep seconds. It is added to the time at which OTPs are computed,
+	// so that a token known to drift can be verified without widening the
+	// skew. It may be at most MaxClockOffset either way.
+	ClockOffset time.Duration `json:"clock_offset,omitempty"`
+	// Separates the OTPs of keys sharing a secret-key; see HOTPKey.Domain.
+	Domain string `json:"domain,omitempty"`
+
+	// If non-nil, the base-32 encoding of SecretKey; see SetBase32Encoding.
+	alphabet *base32.Encoding
+	// If non-nil, the hash function, in place of HashFunction; see SetHash.
+	hashFn *hashFactory
+	// Whether secret-keys shorter than MinKeySize are allowed; see
+	// SetAllowShortSecret.
+	allowShort bool
 }
 
+// The largest ClockOffset, either way: a day, as the widest search of
+// EstimateDrift is at the default time-step.
+const MaxClockOffset = 24 * time.Hour
+
 // Computes and returns an OTP using the TOTP parameter-set. If the receiver
-// TOTPKey is invalid, the program panics.
+// TOTPKey is invalid, OnInvalidKey is called.
 func (k *TOTPKey) OTP() string {
-	h := k.conv()
-	if !h.Validate() {
-		panic("invalid TOTPKey")
+	return k.OTPAt(time.Now())
+}
+
+// Computes and returns the OTP for time t using the TOTP parameter-set. If the
+// receiver TOTPKey is invalid, OnInvalidKey is called.
+func (k *TOTPKey) OTPAt(t time.Time) string {
+	otp, err := k.GenerateAt(t)
+	if err != nil {
+		invalidKey(err)
+		return ""
+	}
+	return otp
+}
+
+// Computes and returns an OTP using the TOTP parameter-set. Unlike OTP, an
+// error is returned rather than panicking.
+func (k *TOTPKey) Generate() (string, error) {
+	return k.GenerateAt(time.Now())
+}
+
+// Computes and returns the OTP for time t using the TOTP parameter-set. Unlike
+// OTPAt, an error is returned rather than panicking; if the receiver TOTPKey is
+// invalid, it is the error of ValidateDetailed, such as ErrInvalidTimeStep for
+// a TimeStep of 0.
+func (k *TOTPKey) GenerateAt(t time.Time) (string, error) {
+	if err := k.ValidateDetailed(); err != nil {
+		return "", err
+	}
+	h, err := k.conv(t)
+	if err != nil {
+		return "", err
 	}
-	return h.OTP()
+	return h.Generate()
 }
 
-// Converts a TOTPKey into an HOTPKey.
-func (k *TOTPKey) conv() *HOTPKey {
-	steps := (uint64(time.Now().Unix()) - k.T0) / k.TimeStep
+// Sets T0 to the reference time t, from which time-steps are counted, so that
+// it need not be converted to Unix seconds by hand; the time zone of t is
+// irrelevant. Sub-second precision is dropped. The receiver TOTPKey is left
+// unchanged and ErrBeforeT0 returned if t precedes the Unix epoch, and
+// ErrT0InFuture if it is after the current time.
+func (k *TOTPKey) SetReference(t time.Time) error {
+	return k.setReference(t, time.Now())
+}
+
+func (k *TOTPKey) setReference(t, now time.Time) error {
+	if t.Unix() < 0 {
+		return ErrBeforeT0
+	}
+	if t.Unix() > now.Unix() {
+		return ErrT0InFuture
+	}
+	k.T0 = uint64(t.Unix())
+	return nil
+}
+
+// Returns T0 as a time.Time.
+func (k *TOTPKey) Reference() time.Time {
+	return time.Unix(int64(k.T0), 0)
+}
+
+// Returns the HOTP counter underlying the OTP for time t: the number of
+// time-steps elapsed since T0, after adding ClockOffset. ErrBeforeT0 is
+// returned if t precedes T0 (or the Unix epoch), and ErrInvalidTimeStep if
+// TimeStep is 0.
+func (k *TOTPKey) CounterAt(t time.Time) (uint64, error) {
+	t = k.tokenTime(t)
+	// Before T0, the number of steps would underflow.
+	if t.Unix() < 0 || uint64(t.Unix()) < k.T0 {
+		return 0, ErrBeforeT0
+	}
+	if k.TimeStep == 0 {
+		return 0, ErrInvalidTimeStep
+	}
+	return (uint64(t.Unix()) - k.T0) / k.TimeStep, nil
+}
+
+// Returns a deep copy of the TOTPKey, which shares no memory with the receiver.
+func (k *TOTPKey) Clone() *TOTPKey {
+	c := *k
+	return &c
+}
+
+// Reports whether other has the same parameter-set as the receiver TOTPKey:
+// the same secret-key, hash function, digits, time-step, and T0. ClockOffset,
+// which describes a particular token rather than the account, is ignored. See
+// HOTPKey.SameParams.
+func (k *TOTPKey) SameParams(other *TOTPKey) bool {
+	return k.hotp(0).SameParams(other.hotp(0)) &&
+		k.TimeStep == other.TimeStep && k.T0 == other.T0
+}
+
+// Returns the time on the token's clock at the true time t.
+func (k *TOTPKey) tokenTime(t time.Time) time.Time {
+	return t.Add(k.ClockOffset)
+}
+
+// Converts a TOTPKey into an HOTPKey for time t.
+func (k *TOTPKey) conv(t time.Time) (*HOTPKey, error) {
+	c, err := k.CounterAt(t)
+	if err != nil {
+		return nil, err
+	}
+	return k.hotp(c), nil
+}
+
+// Computes and returns the OTP for the given counter, rather than for a time,
+// using the TOTP parameter-set. If the receiver TOTPKey is invalid,
+// OnInvalidKey is called.
+func (k *TOTPKey) OTPForCounter(counter uint64) string {
+	if err := k.ValidateDetailed(); err != nil {
+		invalidKey(err)
+		return ""
+	}
+	return k.hotp(counter).OTP()
+}
+
+// Converts a TOTPKey into an HOTPKey for the given counter.
+func (k *TOTPKey) hotp(counter uint64) *HOTPKey {
 	return &HOTPKey{
-		k.SecretKey,
-		k.HashFunction,
-		k.Digits,
-		steps,
+		SecretKey:      k.SecretKey,
+		SecretEncoding: k.SecretEncoding,
+		HashFunction:   k.HashFunction,
+		Digits:         k.Digits,
+		Counter:        counter,
+		Domain:         k.Domain,
+		alphabet:       k.alphabet,
+		hashFn:         k.hashFn,
+		allowShort:     k.allowShort,
+	}
+}
+
+// Returns the number of seconds for which the current OTP remains valid. At a
+// time-step boundary, this is the full TimeStep. If the receiver TOTPKey is
+// invalid, OnInvalidKey is called.
+func (k *TOTPKey) SecondsRemaining() uint64 {
+	return k.secondsRemainingAt(time.Now())
+}
+
+// Returns the time at which the current OTP expires. If the receiver TOTPKey
+// is invalid, OnInvalidKey is called.
+func (k *TOTPKey) ExpiresAt() time.Time {
+	return k.expiresAt(time.Now())
+}
+
+// Returns how far through the current time-step the current time is, from 0
+// at its start towards 1 at its end, for animating the expiry of OTPs. It has
+// sub-second precision. If TimeStep is 0, or the current time precedes T0, 0
+// is returned.
+func (k *TOTPKey) Progress() float64 {
+	return k.progressAt(time.Now())
+}
+
+func (k *TOTPKey) progressAt(t time.Time) float64 {
+	t = k.tokenTime(t)
+	if k.TimeStep == 0 || t.Unix() < 0 || uint64(t.Unix()) < k.T0 {
+		return 0
 	}
+	elapsed := (uint64(t.Unix()) - k.T0) % k.TimeStep
+	return (float64(elapsed) + float64(t.Nanosecond())/1e9) / float64(k.TimeStep)
+}
+
+// Computes and returns the current OTP along with the time at which it
+// expires. Both are computed from a single reading of the clock, so unlike
+// separate calls to OTP and ExpiresAt, they cannot straddle a time-step
+// boundary. If the receiver TOTPKey is invalid, OnInvalidKey is called.
+func (k *TOTPKey) OTPWithExpiry() (code string, expiresAt time.Time) {
+	return k.otpWithExpiryAt(time.Now())
 }
 
-// Validates a TOTPKey.
+func (k *TOTPKey) otpWithExpiryAt(t time.Time) (string, time.Time) {
+	return k.OTPAt(t), k.expiresAt(t)
+}
+
+// Computes and returns the current OTP, the OTP of the next time-step, and the
+// time at which the current one expires, for displays that show the next OTP
+// ahead of a rollover. All are computed from a single reading of the clock, so
+// the OTPs are always of adjacent time-steps. If the receiver TOTPKey is
+// invalid, OnInvalidKey is called.
+func (k *TOTPKey) CurrentAndNext() (current, next string, rollover time.Time) {
+	return k.currentAndNextAt(time.Now())
+}
+
+func (k *TOTPKey) currentAndNextAt(t time.Time) (string, string, time.Time) {
+	if err := k.ValidateDetailed(); err != nil {
+		invalidKey(err)
+		return "", "", time.Time{}
+	}
+	h, err := k.conv(t)
+	if err != nil {
+		invalidKey(err)
+		return "", "", time.Time{}
+	}
+	g, err := h.generator()
+	if err != nil {
+		invalidKey(err)
+		return "", "", time.Time{}
+	}
+	current, err := g.generate(h.Counter)
+	if err != nil {
+		invalidKey(err)
+		return "", "", time.Time{}
+	}
+	// At the last counter, there is no next time-step.
+	var next string
+	if h.Counter < math.MaxUint64 {
+		if next, err = g.generate(h.Counter + 1); err != nil {
+			invalidKey(err)
+			return "", "", time.Time{}
+		}
+	}
+	return current, next, k.expiresAt(t)
+}
+
+func (k *TOTPKey) secondsRemainingAt(t time.Time) uint64 {
+	if err := k.ValidateDetailed(); err != nil {
+		invalidKey(err)
+		return 0
+	}
+	c, err := k.CounterAt(t)
+	if err != nil {
+		invalidKey(err)
+		return 0
+	}
+	elapsed := uint64(k.tokenTime(t).Unix()) - k.T0 - c*k.TimeStep
+	return k.TimeStep - elapsed
+}
+
+func (k *TOTPKey) expiresAt(t time.Time) time.Time {
+	return time.Unix(t.Unix()+int64(k.secondsRemainingAt(t)), 0)
+}
+
+// Validates a TOTPKey. A T0 later than the current time is invalid.
 func (k *TOTPKey) Validate() bool {
-	return k.T0 >= 0 && k.TimeStep > 0 && k.conv().Validate()
+	return k.ValidateDetailed() == nil
+}
+
+// Validates a TOTPKey, returning an error describing why it is invalid, if it
+// is: any error of HOTPKey.ValidateDetailed, ErrInvalidTimeStep,
+// ErrT0InFuture, ErrClockOffset, or ErrBeforeT0 if ClockOffset puts the
+// current time before T0.
+func (k *TOTPKey) ValidateDetailed() error {
+	if err := k.hotp(0).ValidateDetailed(); err != nil {
+		return err
+	}
+	if k.TimeStep == 0 {
+		return ErrInvalidTimeStep
+	}
+	if now := time.Now().Unix(); now < 0 || k.T0 > uint64(now) {
+		return ErrT0InFuture
+	}
+	if k.ClockOffset > MaxClockOffset || k.ClockOffset < -MaxClockOffset {
+		return ErrClockOffset
+	}
+	// A negative ClockOffset can put the token's clock before a recent T0.
+	if _, err := k.CounterAt(time.Now()); err != nil {
+		return err
+	}
+	return nil
 }
@@ -1,7 +1,17 @@
 package otp
 
 import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestHOTP(t *testing.T) {
@@ -9,15 +19,15 @@ func TestHOTP(t *testing.T) {
 		HOTPKey
 		expect string
 	}{
-		{HOTPKey{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SHA1, 8, 0x0000000000000001}, "94287082"},
-		{HOTPKey{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SHA1, 8, 0x00000000023523EC}, "07081804"},
-		{HOTPKey{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SHA1, 8, 0x0000000027BC86AA}, "65353130"},
-		{HOTPKey{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", SHA256, 8, 0x0000000000000001}, "46119246"},
-		{HOTPKey{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", SHA256, 8, 0x00000000023523EC}, "68084774"},
-		{HOTPKey{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", SHA256, 8, 0x0000000027BC86AA}, "77737706"},
-		{HOTPKey{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=", SHA512, 8, 0x0000000000000001}, "90693936"},
-		{HOTPKey{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=", SHA512, 8, 0x00000000023523EC}, "25091201"},
-		{HOTPKey{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=", SHA512, 8, 0x0000000027BC86AA}, "47863826"},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, Counter: 0x0000000000000001}, "94287082"},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, Counter: 0x00000000023523EC}, "07081804"},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, Counter: 0x0000000027BC86AA}, "65353130"},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", HashFunction: SHA256, Digits: 8, Counter: 0x0000000000000001}, "46119246"},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", HashFunction: SHA256, Digits: 8, Counter: 0x00000000023523EC}, "68084774"},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", HashFunction: SHA256, Digits: 8, Counter: 0x0000000027BC86AA}, "77737706"},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=", HashFunction: SHA512, Digits: 8, Counter: 0x0000000000000001}, "90693936"},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=", HashFunction: SHA512, Digits: 8, Counter: 0x00000000023523EC}, "25091201"},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=", HashFunction: SHA512, Digits: 8, Counter: 0x0000000027BC86AA}, "47863826"},
 	}
 	for _, v := range w {
 		otp := v.OTP()
@@ -29,11 +39,415 @@ func TestHOTP(t *testing.T) {
 	}
 }
 
+func TestDigits(t *testing.T) {
+	// The truncated value for this key is 1094287082 (RFC 4226 appendix D).
+	w := map[byte]string{
+		1:  "2",
+		6:  "287082",
+		8:  "94287082",
+		9:  "094287082",
+		10: "1094287082",
+	}
+	for d, expect := range w {
+		k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: d, Counter: 1}
+		if otp := k.OTP(); otp != expect {
+			t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", k, expect, otp)
+		}
+	}
+}
+
+func TestHOTPAt(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 3}
+	// RFC 4226 appendix D values for counters 0 through 9.
+	want := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+	for i, v := range want {
+		if otp := k.OTPAt(uint64(i)); otp != v {
+			t.Errorf("Mismatch on counter %d\nWant: %s Got: %s", i, v, otp)
+		}
+		h := k
+		h.Counter = uint64(i)
+		if otp := h.OTP(); otp != k.OTPAt(uint64(i)) {
+			t.Errorf("Mismatch with OTP on counter %d\nWant: %s Got: %s", i, otp, k.OTPAt(uint64(i)))
+		}
+	}
+	if k.Counter != 3 {
+		t.Errorf("Failure: OTPAt mutated the receiver's counter to %d", k.Counter)
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	w := []struct {
+		b      uint32
+		digits byte
+		expect string
+	}{
+		{42, 5, "00042"},
+		{42, 2, "42"},
+		{42, 1, "2"},
+		{0, 6, "000000"},
+		{1094287082, 6, "287082"},
+		{1094287082, 10, "1094287082"},
+		{1094287082, 8, "94287082"},
+		{0x7FFFFFFF, 8, "47483647"},
+		{99999999, 8, "99999999"},
+		{100000000, 8, "00000000"},
+		{0x7FFFFFFF, 10, "2147483647"},
+		{7, 10, "0000000007"},
+	}
+	for _, v := range w {
+		if got := formatDecimal(v.b, v.digits); got != v.expect {
+			t.Errorf("Mismatch on formatDecimal(%d, %d):\nWant: %s Got: %s", v.b, v.digits, v.expect, got)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	// RFC 4226 appendix D.
+	w := []uint32{
+		0x4c93cf18, 0x41397eea, 0x82fef30, 0x66ef7655, 0x61c5938a,
+		0x33c083d4, 0x7256c032, 0x4e5b397, 0x2823443f, 0x2679dc69,
+	}
+	for i, v := range w {
+		k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: uint64(i)}
+		b, err := k.Value()
+		if err != nil || b != v {
+			t.Errorf("Mismatch on key %+v:\nWant: %#x Got: %#x (%v)", k, v, b, err)
+		}
+		if otp := k.OTP(); otp != formatDecimal(b, 6) {
+			t.Errorf("Mismatch between OTP %s and value %d", otp, b)
+		}
+	}
+	k := HOTPKey{SecretKey: "NOTBASE32 . . .", HashFunction: SHA1, Digits: 6}
+	if _, err := k.Value(); !errors.Is(err, ErrBadBase32) {
+		t.Errorf("Failure: invalid key: got error %v, want %v", err, ErrBadBase32)
+	}
+}
+
+func TestHexEncoding(t *testing.T) {
+	// The RFC 4226 appendix D truncated values, in both encodings.
+	w := []struct {
+		counter uint64
+		digits  byte
+		dec     string
+		hex     string
+	}{
+		{0, 6, "755224", "93cf18"},
+		{0, 8, "84755224", "4c93cf18"},
+		{1, 6, "287082", "397eea"},
+		{1, 8, "94287082", "41397eea"},
+		{2, 6, "359152", "2fef30"},
+		{2, 8, "37359152", "082fef30"},
+		{2, 1, "2", "0"},
+	}
+	for _, v := range w {
+		k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: v.digits, Counter: v.counter}
+		if otp := k.OTP(); otp != v.dec {
+			t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", k, v.dec, otp)
+		}
+		k.Encoding = Hex
+		if otp := k.OTP(); otp != v.hex {
+			t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", k, v.hex, otp)
+		}
+	}
+
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 9, Encoding: Hex}
+	if err := k.ValidateDetailed(); err != ErrDigitsOutOfRange {
+		t.Errorf("Failure: 9 hex digits: got %v, want %v", err, ErrDigitsOutOfRange)
+	}
+	k.Encoding = Hex + 1
+	k.Digits = 6
+	if err := k.ValidateDetailed(); err != ErrUnknownOutput {
+		t.Errorf("Failure: unknown output encoding: got %v, want %v", err, ErrUnknownOutput)
+	}
+}
+
+// Computes an OTP naively, constructing an HMAC afresh, independently of
+// HOTPKey.Generate.
+func referenceOTP(k *HOTPKey) string {
+	sk, _ := decodeSecret(k.SecretKey, k.SecretEncoding)
+	mac := hmac.New(lookupHash(k.HashFunction), sk)
+	var ctr [8]byte
+	binary.BigEndian.PutUint64(ctr[:], k.Counter)
+	mac.Write(ctr[:])
+	sum := mac.Sum(nil)
+	i := int(sum[len(sum)-1] & 0x0F)
+	if k.TruncationOffset != nil {
+		i = *k.TruncationOffset
+	}
+	b := binary.BigEndian.Uint32(sum[i:]) & 0x7FFFFFFF
+	if k.Encoding == Hex {
+		return fmt.Sprintf("%0*x", k.Digits, uint64(b)&(1<<(4*uint(k.Digits))-1))
+	}
+	return fmt.Sprintf("%0*d", k.Digits, uint64(b)%pow10(k.Digits))
+}
+
+func TestCounterBytes(t *testing.T) {
+	w := []struct {
+		counter uint64
+		expect  [8]byte
+	}{
+		{0, [8]byte{0, 0, 0, 0, 0, 0, 0, 0}},
+		{1, [8]byte{0, 0, 0, 0, 0, 0, 0, 1}},
+		{255, [8]byte{0, 0, 0, 0, 0, 0, 0, 0xFF}},
+		{256, [8]byte{0, 0, 0, 0, 0, 0, 1, 0}},
+		{0x0102030405060708, [8]byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{math.MaxUint64, [8]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+	}
+	for _, v := range w {
+		if got := counterBytes(v.counter); got != v.expect {
+			t.Errorf("Mismatch on counter %d:\nWant: %x Got: %x", v.counter, v.expect, got)
+		}
+	}
+
+	// The RFC 4226 appendix D values for counters 0 and 1, and values at the
+	// byte boundaries computed with Python's hmac and struct.pack(">Q", c).
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}
+	for _, v := range []struct {
+		counter uint64
+		expect  string
+	}{
+		{0, "755224"},
+		{1, "287082"},
+		{255, "865784"},
+		{256, "620188"},
+		{math.MaxUint64, "094451"},
+	} {
+		if otp := k.OTPAt(v.counter); otp != v.expect {
+			t.Errorf("Mismatch on counter %d:\nWant: %s Got: %s", v.counter, v.expect, otp)
+		}
+	}
+}
+
+func TestGeneratorReuse(t *testing.T) {
+	// A reused HMAC must yield the same OTPs as fresh ones, in any order.
+	offset := 7
+	for _, k := range []HOTPKey{
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA", HashFunction: SHA256, Digits: 8},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA", HashFunction: SHA512, Digits: 10},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, Encoding: Hex},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TruncationOffset: &offset},
+	} {
+		g, err := k.generator()
+		if err != nil {
+			t.Fatalf("Failure: key %+v: %v", k, err)
+		}
+		for _, c := range []uint64{0, 1, 2, 1 << 32, 3, 3, 0, 1<<64 - 1, 59} {
+			k.Counter = c
+			otp, err := g.generate(c)
+			if want := referenceOTP(&k); err != nil || otp != want {
+				t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s (%v)", k, want, otp, err)
+			}
+			if otp2 := k.OTP(); otp2 != otp {
+				t.Errorf("Mismatch with OTP on key %+v:\nWant: %s Got: %s", k, otp, otp2)
+			}
+		}
+	}
+}
+
+func TestTruncationOffset(t *testing.T) {
+	dynamic := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 0}
+	fixed := dynamic
+	off := 0
+	fixed.TruncationOffset = &off
+	// The HMAC for counter 0 is cc93cf18508d94934c64b65d8ba7667fb7cde4b0
+	// (RFC 4226 appendix D), whose last nibble selects offset 0 anyway.
+	if dynamic.OTP() != "755224" || fixed.OTP() != "755224" {
+		t.Errorf("Mismatch at offset 0: dynamic %s, fixed %s, want 755224", dynamic.OTP(), fixed.OTP())
+	}
+
+	// For counter 1, the HMAC is 75a48a19d4cbe100644e8ac1397eea747a2d33ab,
+	// whose last nibble selects offset 11.
+	dynamic.Counter, fixed.Counter = 1, 1
+	w := map[int]string{0: "717529", 11: "287082", 15: "164019", 16: ""}
+	for o, expect := range w {
+		o := o
+		fixed.TruncationOffset = &o
+		otp, err := fixed.Generate()
+		if expect == "" {
+			if !errors.Is(fixed.ValidateDetailed(), ErrOffsetOutOfRange) || err == nil {
+				t.Errorf("Failure: offset %d accepted", o)
+			}
+			continue
+		}
+		if otp != expect {
+			t.Errorf("Mismatch at offset %d:\nWant: %s Got: %s (%v)", o, expect, otp, err)
+		}
+	}
+	if dynamic.OTP() != w[11] {
+		t.Errorf("Mismatch between dynamic truncation and offset 11:\n%s != %s", dynamic.OTP(), w[11])
+	}
+	o := -1
+	fixed.TruncationOffset = &o
+	if fixed.Validate() {
+		t.Errorf("Failure: offset -1 accepted")
+	}
+}
+
+func TestValidateDetailed(t *testing.T) {
+	w := []struct {
+		HOTPKey
+		expect error
+	}{
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}, nil},
+		{HOTPKey{SecretKey: "NOTBASE32 . . .", HashFunction: SHA1, Digits: 6}, ErrBadBase32},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SecretEncoding: 9, HashFunction: SHA1, Digits: 6}, ErrUnknownEncoding},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}, ErrSecretTooShort},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: "SHA384", Digits: 6}, ErrUnknownHash},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 0}, ErrZeroDigits},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 11}, ErrDigitsOutOfRange},
+	}
+	for _, v := range w {
+		if err := v.ValidateDetailed(); !errors.Is(err, v.expect) {
+			t.Errorf("Mismatch on key %+v:\nWant: %v Got: %v", v.HOTPKey, v.expect, err)
+		}
+		if v.Validate() != (v.expect == nil) {
+			t.Errorf("Mismatch between Validate and ValidateDetailed on key %+v", v.HOTPKey)
+		}
+	}
+
+	tw := []struct {
+		TOTPKey
+		expect error
+	}{
+		{TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}, nil},
+		{TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}, ErrInvalidTimeStep},
+		{TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, T0: 1 << 40}, ErrT0InFuture},
+		{TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 12, TimeStep: 30}, ErrDigitsOutOfRange},
+	}
+	for _, v := range tw {
+		if err := v.ValidateDetailed(); !errors.Is(err, v.expect) {
+			t.Errorf("Mismatch on key %+v:\nWant: %v Got: %v", v.TOTPKey, v.expect, err)
+		}
+		if v.Validate() != (v.expect == nil) {
+			t.Errorf("Mismatch between Validate and ValidateDetailed on key %+v", v.TOTPKey)
+		}
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	// These bypass Validate, and must not yield an OTP from partial bytes.
+	w := []struct {
+		secret string
+		expect error
+	}{
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJ!", ErrBadBase32},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ0", ErrBadBase32},
+		{"GEZDGNBVGY3TQOJQGEZDGNBV========", ErrBadBase32},
+		{"GEZDGNBVGY3TQOJQ", ErrSecretTooShort},
+	}
+	for _, v := range w {
+		hk := HOTPKey{SecretKey: v.secret, HashFunction: SHA1, Digits: 6}
+		if otp, err := hk.Generate(); !errors.Is(err, v.expect) || otp != "" {
+			t.Errorf("Failure on secret %q: got (%q, %v), want %v", v.secret, otp, err, v.expect)
+		}
+		tk := TOTPKey{SecretKey: v.secret, HashFunction: SHA1, Digits: 6, TimeStep: 30}
+		if otp, err := tk.Generate(); !errors.Is(err, v.expect) || otp != "" {
+			t.Errorf("Failure on secret %q: got (%q, %v), want %v", v.secret, otp, err, v.expect)
+		}
+	}
+}
+
+func TestGenerateZeroTimeStep(t *testing.T) {
+	// Time-steps are counted by dividing by TimeStep, which must not panic.
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}
+	if otp, err := k.GenerateAt(time.Unix(59, 0)); err != ErrInvalidTimeStep || otp != "" {
+		t.Errorf("Failure: got (%q, %v), want %v", otp, err, ErrInvalidTimeStep)
+	}
+	if otp, err := k.Generate(); err != ErrInvalidTimeStep || otp != "" {
+		t.Errorf("Failure: got (%q, %v), want %v", otp, err, ErrInvalidTimeStep)
+	}
+
+	defer func(f func(error)) { OnInvalidKey = f }(OnInvalidKey)
+	var got []error
+	OnInvalidKey = func(err error) { got = append(got, err) }
+	if otp := k.OTPAt(time.Unix(59, 0)); otp != "" {
+		t.Errorf("Mismatch on OTP\nWant: %q Got: %q", "", otp)
+	}
+	if len(got) != 1 || got[0] != ErrInvalidTimeStep {
+		t.Errorf("Failure: hook called with %v", got)
+	}
+	got = nil
+	if s := k.secondsRemainingAt(time.Unix(59, 0)); s != 0 {
+		t.Errorf("Mismatch on seconds remaining\nWant: 0 Got: %d", s)
+	}
+	if len(got) != 1 || got[0] != ErrInvalidTimeStep {
+		t.Errorf("Failure: hook called with %v", got)
+	}
+	if p := k.progressAt(time.Unix(59, 0)); p != 0 {
+		t.Errorf("Mismatch on progress\nWant: 0 Got: %v", p)
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := 3
+	k := &HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 1, TruncationOffset: &o}
+	c := k.Clone()
+	if c == k || *c.TruncationOffset != 3 || c.Counter != 1 || c.SecretKey != k.SecretKey {
+		t.Fatalf("Failure: clone differs from original: %+v", c)
+	}
+	c.Counter++
+	*c.TruncationOffset = 5
+	if k.Counter != 1 || *k.TruncationOffset != 3 {
+		t.Errorf("Failure: mutating the clone mutated the original: %+v", k)
+	}
+	if (&HOTPKey{}).Clone().TruncationOffset != nil {
+		t.Errorf("Failure: clone of a dynamic-truncation key has a fixed offset")
+	}
+
+	tk := &TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	tc := tk.Clone()
+	if *tc != *tk || tc == tk {
+		t.Fatalf("Failure: clone differs from original: %+v", tc)
+	}
+	tc.T0 = 60
+	if tk.T0 != 0 {
+		t.Errorf("Failure: mutating the clone mutated the original: %+v", tk)
+	}
+}
+
+func TestZeroDigits(t *testing.T) {
+	hk := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1}
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, TimeStep: 30}
+	for _, e := range []OutputEncoding{Decimal, Hex} {
+		hk.Encoding = e
+		if otp, err := hk.Generate(); err != ErrZeroDigits || otp != "" {
+			t.Errorf("Failure: HOTP with encoding %d: got (%q, %v), want %v", e, otp, err, ErrZeroDigits)
+		}
+	}
+	if !errors.Is(ErrZeroDigits, ErrDigitsOutOfRange) {
+		t.Errorf("Failure: ErrZeroDigits does not wrap ErrDigitsOutOfRange")
+	}
+	if otp, err := tk.GenerateAt(time.Unix(59, 0)); err != ErrZeroDigits || otp != "" {
+		t.Errorf("Failure: TOTP: got (%q, %v), want %v", otp, err, ErrZeroDigits)
+	}
+	var b strings.Builder
+	if err := hk.Stream(&b, 3, ","); err != ErrZeroDigits || b.Len() != 0 {
+		t.Errorf("Failure: Stream: wrote %q (%v)", b.String(), err)
+	}
+	if otp, err := hk.Next(); err != ErrZeroDigits || otp != "" || hk.Counter != 0 {
+		t.Errorf("Failure: Next: got (%q, %v)", otp, err)
+	}
+
+	// No successful call yields an empty OTP, or one of the wrong length.
+	for d := byte(0); d <= MaxDigits+1; d++ {
+		hk := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: d}
+		tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: d, TimeStep: 30}
+		hex := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: d, Encoding: Hex}
+		for _, g := range []OTPGenerator{&hk, &tk, &hex} {
+			if otp, err := g.Generate(); err == nil && len(otp) != int(d) {
+				t.Errorf("Failure: key %v yields %q", g, otp)
+			}
+		}
+	}
+}
+
 func TestValidate(t *testing.T) {
 	invalid := []HOTPKey{
-		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "SHA384", 8, 0x0000000000000001},
-		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SHA1, 11, 0x0000000000000001},
-		{"NOTBASE32 . . .", SHA1, 6, 0x0000000000000001},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: "SHA384", Digits: 8, Counter: 0x0000000000000001},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 11, Counter: 0x0000000000000001},
+		{SecretKey: "NOTBASE32 . . .", HashFunction: SHA1, Digits: 6, Counter: 0x0000000000000001},
 	}
 	for _, v := range invalid {
 		if v.Validate() {
@@ -44,9 +458,9 @@ func TestValidate(t *testing.T) {
 	}
 
 	valid := []HOTPKey{
-		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "SHA1", 1, 0x0000000000000001},
-		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", "SHA256", 3, 0x0000000000000001},
-		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=", "SHA512", 10, 0x0000000027BC86AA},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: "SHA1", Digits: 1, Counter: 0x0000000000000001},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", HashFunction: "SHA256", Digits: 3, Counter: 0x0000000000000001},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=", HashFunction: "SHA512", Digits: 10, Counter: 0x0000000027BC86AA},
 	}
 	for _, v := range valid {
 		if !v.Validate() {
@@ -56,3 +470,648 @@ func TestValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestTOTPAt(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30, T0: 0}
+	w := []struct {
+		at      int64
+		counter uint64
+	}{
+		{0, 0}, {29, 0}, {30, 1}, {59, 1}, {60, 2}, {1111111109, 37037036},
+	}
+	for _, v := range w {
+		h := HOTPKey{SecretKey: k.SecretKey, HashFunction: k.HashFunction, Digits: k.Digits, Counter: v.counter}
+		otp := k.OTPAt(time.Unix(v.at, 0))
+		if otp != h.OTP() {
+			t.Errorf("Mismatch at time %d:\nWant: %s Got: %s", v.at, h.OTP(), otp)
+		}
+	}
+}
+
+func TestOTPForCounter(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	// The RFC 6238 appendix B values, by their "T" values.
+	w := []struct {
+		counter uint64
+		expect  string
+	}{
+		{0x1, "94287082"},
+		{0x23523EC, "07081804"},
+		{0x23523ED, "14050471"},
+		{0x273EF07, "89005924"},
+		{0x3F940AA, "69279037"},
+		{0x27BC86AA, "65353130"},
+	}
+	for _, v := range w {
+		if otp := k.OTPForCounter(v.counter); otp != v.expect {
+			t.Errorf("Mismatch on counter %d\nWant: %s Got: %s", v.counter, v.expect, otp)
+		}
+	}
+	// Independently of T0, unlike OTPAt.
+	k.T0 = 1 << 20
+	if otp := k.OTPForCounter(1); otp != "94287082" {
+		t.Errorf("Mismatch on counter 1 with T0 %d\nWant: 94287082 Got: %s", k.T0, otp)
+	}
+}
+
+func TestOTPWithExpiry(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	// The RFC 6238 appendix B values for times 1111111109 and 1111111111,
+	// either side of the boundary at 1111111110.
+	w := []struct {
+		t       time.Time
+		code    string
+		expires int64
+	}{
+		{time.Unix(1111111109, 0), "07081804", 1111111110},
+		{time.Unix(1111111109, 999999999), "07081804", 1111111110},
+		{time.Unix(1111111110, 0), "14050471", 1111111140},
+		{time.Unix(1111111111, 0), "14050471", 1111111140},
+	}
+	for _, v := range w {
+		code, exp := k.otpWithExpiryAt(v.t)
+		if code != v.code || exp.Unix() != v.expires {
+			t.Errorf("Mismatch on time %v\nWant: %s %d Got: %s %d", v.t, v.code, v.expires, code, exp.Unix())
+		}
+	}
+	code, exp := k.OTPWithExpiry()
+	if !k.VerifyAt(code, exp.Add(-time.Second), 0) || k.VerifyAt(code, exp, 0) {
+		t.Errorf("Failure: code %s does not expire at %v", code, exp)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	w := []struct {
+		t      time.Time
+		expect float64
+	}{
+		{time.Unix(0, 0), 0},
+		{time.Unix(15, 0), 0.5},
+		{time.Unix(29, 500000000), 29.5 / 30},
+		{time.Unix(30, 0), 0},
+		{time.Unix(1111111111, 0), 1.0 / 30},
+	}
+	for _, v := range w {
+		if p := k.progressAt(v.t); p != v.expect {
+			t.Errorf("Mismatch on time %v\nWant: %v Got: %v", v.t, v.expect, p)
+		}
+	}
+	prev := -1.0
+	for ns := int64(0); ns < 30e9; ns += 1e8 {
+		p := k.progressAt(time.Unix(60, ns))
+		if p <= prev || p >= 1 {
+			t.Fatalf("Failure: progress %v at %d ns after step start, after %v", p, ns, prev)
+		}
+		prev = p
+	}
+	if p := k.Progress(); p < 0 || p >= 1 {
+		t.Errorf("Failure: current progress is %v", p)
+	}
+
+	k.T0 = 100
+	if p := k.progressAt(time.Unix(99, 0)); p != 0 {
+		t.Errorf("Failure: progress before T0 is %v", p)
+	}
+	if p := k.progressAt(time.Unix(106, 0)); p != 0.2 {
+		t.Errorf("Mismatch on time 106 with T0 100\nWant: 0.2 Got: %v", p)
+	}
+	k.TimeStep = 0
+	if p := k.progressAt(time.Unix(200, 0)); p != 0 {
+		t.Errorf("Failure: progress with a zero time-step is %v", p)
+	}
+}
+
+func TestCounterAt(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	// The RFC 6238 appendix B times and their hexadecimal "T" values.
+	w := []struct {
+		unix   int64
+		expect uint64
+	}{
+		{59, 0x1},
+		{1111111109, 0x23523EC},
+		{1111111111, 0x23523ED},
+		{1234567890, 0x273EF07},
+		{2000000000, 0x3F940AA},
+		{20000000000, 0x27BC86AA},
+	}
+	for _, v := range w {
+		c, err := k.CounterAt(time.Unix(v.unix, 0))
+		if err != nil || c != v.expect {
+			t.Errorf("Mismatch on time %d\nWant: %d Got: %d (%v)", v.unix, v.expect, c, err)
+		}
+		h, _ := k.conv(time.Unix(v.unix, 0))
+		if h.Counter != c {
+			t.Errorf("Mismatch with conv on time %d\nWant: %d Got: %d", v.unix, c, h.Counter)
+		}
+	}
+
+	k.T0 = 100
+	if c, err := k.CounterAt(time.Unix(99, 0)); err != ErrBeforeT0 {
+		t.Errorf("Failure: time before T0: got (%d, %v), want %v", c, err, ErrBeforeT0)
+	}
+	if c, err := k.CounterAt(time.Unix(130, 0)); err != nil || c != 1 {
+		t.Errorf("Mismatch on time 130 with T0 100\nWant: 1 Got: %d (%v)", c, err)
+	}
+	if _, err := k.CounterAt(time.Unix(-1, 0)); err != ErrBeforeT0 {
+		t.Errorf("Failure: time before epoch: got %v, want %v", err, ErrBeforeT0)
+	}
+	k.TimeStep = 0
+	if _, err := k.CounterAt(time.Unix(130, 0)); err != ErrInvalidTimeStep {
+		t.Errorf("Failure: zero time-step: got %v, want %v", err, ErrInvalidTimeStep)
+	}
+}
+
+func TestSecondsRemaining(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, T0: 10}
+	w := []struct {
+		at        int64
+		remaining uint64
+	}{
+		{10, 30}, {11, 29}, {39, 1}, {40, 30}, {65, 5},
+	}
+	for _, v := range w {
+		at := time.Unix(v.at, 0)
+		if got := k.secondsRemainingAt(at); got != v.remaining {
+			t.Errorf("Mismatch at time %d:\nWant: %d Got: %d", v.at, v.remaining, got)
+		}
+		want := time.Unix(v.at+int64(v.remaining), 0)
+		if got := k.expiresAt(at); !got.Equal(want) {
+			t.Errorf("Mismatch at time %d:\nWant: %v Got: %v", v.at, want, got)
+		}
+	}
+
+	if r := k.SecondsRemaining(); r < 1 || r > k.TimeStep {
+		t.Errorf("Failure: %d seconds remaining is out of range", r)
+	}
+}
+
+// RFC 6238 appendix B.
+func TestTOTP(t *testing.T) {
+	const (
+		seed1   = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+		seed256 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA===="
+		seed512 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA="
+	)
+	w := []struct {
+		TOTPKey
+		at     int64
+		expect string
+	}{
+		{TOTPKey{SecretKey: seed1, HashFunction: SHA1, Digits: 8, TimeStep: 30, T0: 0}, 59, "94287082"},
+		{TOTPKey{SecretKey: seed256, HashFunction: SHA256, Digits: 8, TimeStep: 30, T0: 0}, 59, "46119246"},
+		{TOTPKey{SecretKey: seed512, HashFunction: SHA512, Digits: 8, TimeStep: 30, T0: 0}, 59, "90693936"},
+		{TOTPKey{SecretKey: seed1, HashFunction: SHA1, Digits: 8, TimeStep: 30, T0: 0}, 1111111109, "07081804"},
+		{TOTPKey{SecretKey: seed256, HashFunction: SHA256, Digits: 8, TimeStep: 30, T0: 0}, 1111111109, "68084774"},
+		{TOTPKey{SecretKey: seed512, HashFunction: SHA512, Digits: 8, TimeStep: 30, T0: 0}, 1111111109, "25091201"},
+		{TOTPKey{SecretKey: seed1, HashFunction: SHA1, Digits: 8, TimeStep: 30, T0: 0}, 1111111111, "14050471"},
+		{TOTPKey{SecretKey: seed256, HashFunction: SHA256, Digits: 8, TimeStep: 30, T0: 0}, 1111111111, "67062674"},
+		{TOTPKey{SecretKey: seed512, HashFunction: SHA512, Digits: 8, TimeStep: 30, T0: 0}, 1111111111, "99943326"},
+		{TOTPKey{SecretKey: seed1, HashFunction: SHA1, Digits: 8, TimeStep: 30, T0: 0}, 1234567890, "89005924"},
+		{TOTPKey{SecretKey: seed256, HashFunction: SHA256, Digits: 8, TimeStep: 30, T0: 0}, 1234567890, "91819424"},
+		{TOTPKey{SecretKey: seed512, HashFunction: SHA512, Digits: 8, TimeStep: 30, T0: 0}, 1234567890, "93441116"},
+		{TOTPKey{SecretKey: seed1, HashFunction: SHA1, Digits: 8, TimeStep: 30, T0: 0}, 2000000000, "69279037"},
+		{TOTPKey{SecretKey: seed256, HashFunction: SHA256, Digits: 8, TimeStep: 30, T0: 0}, 2000000000, "90698825"},
+		{TOTPKey{SecretKey: seed512, HashFunction: SHA512, Digits: 8, TimeStep: 30, T0: 0}, 2000000000, "38618901"},
+		{TOTPKey{SecretKey: seed1, HashFunction: SHA1, Digits: 8, TimeStep: 30, T0: 0}, 20000000000, "65353130"},
+		{TOTPKey{SecretKey: seed256, HashFunction: SHA256, Digits: 8, TimeStep: 30, T0: 0}, 20000000000, "77737706"},
+		{TOTPKey{SecretKey: seed512, HashFunction: SHA512, Digits: 8, TimeStep: 30, T0: 0}, 20000000000, "47863826"},
+	}
+	for _, v := range w {
+		otp := v.OTPAt(time.Unix(v.at, 0))
+		if otp != v.expect {
+			t.Errorf("Mismatch on key %+v at time %d:\nWant: %s Got: %s", v.TOTPKey, v.at, v.expect, otp)
+		} else {
+			t.Logf("Success on key %+v at time %d:\nWant: %s Got: %s", v.TOTPKey, v.at, v.expect, otp)
+		}
+	}
+}
+
+// The RFC 6238 appendix B SHA256 and SHA512 values, with a time-step of 60
+// seconds rather than 30: time 2t+1 falls in the time-step that t does under
+// the RFC's parameters.
+func TestTOTP60(t *testing.T) {
+	const (
+		seed256 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA===="
+		seed512 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA="
+	)
+	w := []struct {
+		seed   string
+		hf     HashFunction
+		at     int64
+		expect string
+	}{
+		{seed256, SHA256, 59, "46119246"},
+		{seed512, SHA512, 59, "90693936"},
+		{seed256, SHA256, 1111111109, "68084774"},
+		{seed512, SHA512, 1111111109, "25091201"},
+		{seed256, SHA256, 1234567890, "91819424"},
+		{seed512, SHA512, 1234567890, "93441116"},
+		{seed256, SHA256, 2000000000, "90698825"},
+		{seed512, SHA512, 2000000000, "38618901"},
+	}
+	for _, v := range w {
+		k, err := NewTOTPKeyFrom(v.seed, v.hf, 8, 60, 0)
+		if err != nil {
+			t.Fatalf("Failure: NewTOTPKeyFrom: %v", err)
+		}
+		at := time.Unix(2*v.at+1, 0)
+		if otp := k.OTPAt(at); otp != v.expect {
+			t.Errorf("Mismatch on %s at time %d:\nWant: %s Got: %s", v.hf, at.Unix(), v.expect, otp)
+		}
+		if !k.VerifyAt(v.expect, at, 0) {
+			t.Errorf("Failure: %s code %s rejected at time %d", v.hf, v.expect, at.Unix())
+		}
+	}
+}
+
+func TestShortDigest(t *testing.T) {
+	// MD5's 16-byte digest is too short for dynamic truncation. RegisterHash
+	// refuses it, so this bypasses it to test the guard in generation.
+	hfMap["MD5"] = md5.New
+	defer delete(hfMap, "MD5")
+
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: "MD5", Digits: 6, Counter: 0}
+	for k.Counter = 0; k.Counter < 64; k.Counter++ {
+		if _, err := k.Generate(); err != ErrShortDigest {
+			t.Fatalf("Failure: counter %d: got error %v, want %v", k.Counter, err, ErrShortDigest)
+		}
+	}
+	if ok, _ := k.Verify("000000", 10); ok {
+		t.Errorf("Failure: code accepted by key with a short digest")
+	}
+}
+
+// Meant to be run with -race.
+func TestConcurrentOTP(t *testing.T) {
+	hks := []HOTPKey{
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, Counter: 1},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", HashFunction: SHA256, Digits: 8, Counter: 1},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=", HashFunction: SHA512, Digits: 8, Counter: 1},
+	}
+	want := []string{"94287082", "46119246", "90693936"}
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30, T0: 0}
+	at := time.Unix(59, 0)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 100; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			if g%10 == 0 {
+				RegisterHash("SHA224", sha256.New224)
+			}
+			for i := 0; i < 50; i++ {
+				j := (g + i) % len(hks)
+				if otp := hks[j].OTP(); otp != want[j] {
+					t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", hks[j], want[j], otp)
+				}
+				if otp := tk.OTPAt(at); otp != want[0] {
+					t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", tk, want[0], otp)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	hfMu.Lock()
+	delete(hfMap, "SHA224")
+	hfMu.Unlock()
+}
+
+func TestOTPGenerator(t *testing.T) {
+	keys := []OTPGenerator{
+		&HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, Counter: 1},
+		&TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30},
+	}
+	for _, k := range keys {
+		if !k.Validate() {
+			t.Errorf("Failure: valid key marked as invalid: %+v", k)
+		}
+		otp, err := k.Generate()
+		if err != nil || len(otp) != 8 {
+			t.Errorf("Failure: key %+v: got %q (%v)", k, otp, err)
+		}
+	}
+}
+
+func TestT0(t *testing.T) {
+	now := time.Now()
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30, T0: uint64(now.Unix() + 3600)}
+	// Before the fix, the steps underflowed to about 2^64/30 and a code was
+	// generated regardless.
+	if k.Validate() {
+		t.Errorf("Failure: key with T0 in the future marked as valid: %+v", k)
+	}
+	if otp, err := k.Generate(); err != ErrT0InFuture {
+		t.Errorf("Failure: key with T0 in the future: got (%q, %v), want %v", otp, err, ErrT0InFuture)
+	}
+
+	k.T0 = uint64(now.Unix() - 3600)
+	if !k.Validate() {
+		t.Fatalf("Failure: key with T0 in the past marked as invalid: %+v", k)
+	}
+	if otp, err := k.GenerateAt(now.Add(-2 * time.Hour)); err != ErrBeforeT0 {
+		t.Errorf("Failure: time before T0: got (%q, %v), want %v", otp, err, ErrBeforeT0)
+	}
+	if otp, err := k.GenerateAt(time.Unix(-1, 0)); err != ErrBeforeT0 {
+		t.Errorf("Failure: time before the epoch: got (%q, %v), want %v", otp, err, ErrBeforeT0)
+	}
+	if ok, _ := k.verifyAt(k.OTPAt(now), now.Add(-2*time.Hour), 1); ok {
+		t.Errorf("Failure: code verified at a time before T0")
+	}
+
+	// T0 shifts the steps.
+	k.T0 = 10
+	h := HOTPKey{SecretKey: k.SecretKey, HashFunction: SHA1, Digits: 8, Counter: 1}
+	if otp := k.OTPAt(time.Unix(69, 0)); otp != h.OTP() {
+		t.Errorf("Mismatch with T0 10:\nWant: %s Got: %s", h.OTP(), otp)
+	}
+}
+
+func TestOnInvalidKey(t *testing.T) {
+	defer func(f func(error)) { OnInvalidKey = f }(OnInvalidKey)
+	var got []error
+	OnInvalidKey = func(err error) { got = append(got, err) }
+
+	hk := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJ!", HashFunction: SHA1, Digits: 6}
+	if otp := hk.OTP(); otp != "" {
+		t.Errorf("Mismatch on invalid HOTPKey OTP\nWant: %q Got: %q", "", otp)
+	}
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, TimeStep: 30}
+	if otp := tk.OTP(); otp != "" {
+		t.Errorf("Mismatch on invalid TOTPKey OTP\nWant: %q Got: %q", "", otp)
+	}
+	if len(got) != 2 || !errors.Is(got[0], ErrBadBase32) || !errors.Is(got[1], ErrZeroDigits) {
+		t.Fatalf("Failure: hook called with %v", got)
+	}
+
+	// Every path passes the specific error of ValidateDetailed.
+	got = nil
+	tk.Codes(2)
+	tk.OTPForCounter(1)
+	tk.SteamCode()
+	tk.SecondsRemaining()
+	mk := MOTPKey{Secret: "0123456789abcdef"}
+	mk.OTP()
+	want := []error{ErrZeroDigits, ErrZeroDigits, ErrZeroDigits, ErrZeroDigits, ErrNoMOTPPIN}
+	if len(got) != len(want) {
+		t.Fatalf("Failure: hook called with %v, want %v", got, want)
+	}
+	for i, err := range got {
+		if err != want[i] {
+			t.Errorf("Mismatch on call %d\nWant: %v Got: %v", i, want[i], err)
+		}
+	}
+	if !errors.Is(ErrNoMOTPPIN, ErrInvalidMOTPKey) {
+		t.Errorf("Failure: %v does not wrap %v", ErrNoMOTPPIN, ErrInvalidMOTPKey)
+	}
+
+	OnInvalidKey = nil
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Failure: nil OnInvalidKey did not panic")
+			}
+		}()
+		hk.OTP()
+	}()
+}
+
+func TestClockOffset(t *testing.T) {
+	base := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	at := time.Unix(1111111109, 0)
+	for _, d := range []time.Duration{-time.Hour, -31 * time.Second, -time.Second, time.Second, 90 * time.Second, MaxClockOffset} {
+		k := base
+		k.ClockOffset = d
+		if err := k.ValidateDetailed(); err != nil {
+			t.Fatalf("Failure: offset %v: %v", d, err)
+		}
+		if got, want := k.OTPAt(at), base.OTPAt(at.Add(d)); got != want {
+			t.Errorf("Mismatch on offset %v:\nWant: %s Got: %s", d, want, got)
+		}
+		if got, want := k.secondsRemainingAt(at), base.secondsRemainingAt(at.Add(d)); got != want {
+			t.Errorf("Mismatch on seconds remaining with offset %v:\nWant: %d Got: %d", d, want, got)
+		}
+		if !k.VerifyAt(base.OTPAt(at.Add(d)), at, 0) {
+			t.Errorf("Failure: offset %v: token's code rejected without skew", d)
+		}
+	}
+
+	// An offset measured with EstimateDrift compensates for the drift.
+	fast := at.Add(5 * 30 * time.Second)
+	off, ok := base.estimateDriftAt(base.OTPAt(fast), at, 10)
+	if !ok || off != 5 {
+		t.Fatalf("Failure: drift estimated as (%d, %v)", off, ok)
+	}
+	k := base
+	k.ClockOffset = time.Duration(off) * time.Duration(k.TimeStep) * time.Second
+	if !k.VerifyAt(base.OTPAt(fast.Add(time.Hour)), at.Add(time.Hour), 0) {
+		t.Errorf("Failure: drifting token's later code rejected")
+	}
+
+	for _, d := range []time.Duration{MaxClockOffset + 1, -MaxClockOffset - 1} {
+		k.ClockOffset = d
+		if err := k.ValidateDetailed(); err != ErrClockOffset {
+			t.Errorf("Failure: offset %v: got %v, want %v", d, err, ErrClockOffset)
+		}
+	}
+
+	// A negative offset can put the token's clock before a recent T0, which
+	// must fail validation rather than panic when generating.
+	k.ClockOffset = -time.Hour
+	k.T0 = uint64(time.Now().Unix()) - 10
+	if err := k.ValidateDetailed(); err != ErrBeforeT0 {
+		t.Errorf("Failure: offset before T0: got %v, want %v", err, ErrBeforeT0)
+	}
+	defer func(f func(error)) { OnInvalidKey = f }(OnInvalidKey)
+	var errs []error
+	OnInvalidKey = func(err error) { errs = append(errs, err) }
+	if s := k.SecondsRemaining(); s != 0 {
+		t.Errorf("Mismatch on seconds remaining before T0\nWant: 0 Got: %d", s)
+	}
+	if len(errs) != 1 || errs[0] != ErrBeforeT0 {
+		t.Errorf("Failure: hook called with %v", errs)
+	}
+	k.ClockOffset = 0
+	if err := k.ValidateDetailed(); err != nil {
+		t.Errorf("Failure: recent T0 without offset: %v", err)
+	}
+	if s := k.SecondsRemaining(); s == 0 || s > k.TimeStep {
+		t.Errorf("Failure: %d seconds remaining", s)
+	}
+}
+
+func TestSameParams(t *testing.T) {
+	o, o2 := 3, 3
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 1, TruncationOffset: &o}
+	w := []struct {
+		other  HOTPKey
+		expect bool
+	}{
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 99, TruncationOffset: &o2}, true},
+		{HOTPKey{SecretKey: "gezd gnbv gy3t qojq gezd gnbv gy3t qojq", HashFunction: "sha1", Digits: 6, TruncationOffset: &o2}, true},
+		{HOTPKey{SecretKey: "12345678901234567890", SecretEncoding: EncodingRaw, HashFunction: "", Digits: 6, TruncationOffset: &o2}, true},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJR", HashFunction: SHA1, Digits: 6, TruncationOffset: &o2}, false},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA256, Digits: 6, TruncationOffset: &o2}, false},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TruncationOffset: &o2}, false},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Encoding: Hex, TruncationOffset: &o2}, false},
+		{HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}, false},
+		{HOTPKey{SecretKey: "NOT BASE32!", HashFunction: SHA1, Digits: 6, TruncationOffset: &o2}, false},
+	}
+	for _, v := range w {
+		if got := k.SameParams(&v.other); got != v.expect {
+			t.Errorf("Mismatch on %s:\nWant: %v Got: %v", v.other.StringWithSecret(), v.expect, got)
+		}
+		if got := v.other.SameParams(&k); got != v.expect {
+			t.Errorf("Mismatch on %s, reversed:\nWant: %v Got: %v", v.other.StringWithSecret(), v.expect, got)
+		}
+	}
+
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	tw := []struct {
+		other  TOTPKey
+		expect bool
+	}{
+		{TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, ClockOffset: time.Minute}, true},
+		{TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 60}, false},
+		{TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, T0: 1}, false},
+		{TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}, false},
+	}
+	for _, v := range tw {
+		if got := tk.SameParams(&v.other); got != v.expect {
+			t.Errorf("Mismatch on %s:\nWant: %v Got: %v", v.other.StringWithSecret(), v.expect, got)
+		}
+	}
+}
+
+func TestSetReference(t *testing.T) {
+	now := time.Unix(2000000000, 0)
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	w := []struct {
+		ref    time.Time
+		expect uint64
+		err    error
+	}{
+		{time.Unix(0, 0), 0, nil},
+		{time.Date(2001, 9, 9, 1, 46, 40, 0, time.UTC), 1000000000, nil},
+		{time.Date(2001, 9, 9, 7, 16, 40, 0, ist), 1000000000, nil},
+		{time.Unix(1000000000, 999999999), 1000000000, nil},
+		{now, 2000000000, nil},
+		{now.Add(time.Second), 0, ErrT0InFuture},
+		{time.Unix(-1, 0), 0, ErrBeforeT0},
+	}
+	for _, v := range w {
+		k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+		if err := k.setReference(v.ref, now); err != v.err {
+			t.Errorf("Failure: reference %v: got error %v, want %v", v.ref, err, v.err)
+			continue
+		}
+		if k.T0 != v.expect {
+			t.Errorf("Mismatch on reference %v:\nWant: %d Got: %d", v.ref, v.expect, k.T0)
+		}
+		if v.err == nil && !k.Reference().Equal(time.Unix(int64(v.expect), 0)) {
+			t.Errorf("Mismatch on Reference of %v:\nWant: %d Got: %v", v.ref, v.expect, k.Reference())
+		}
+	}
+
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	if err := k.SetReference(time.Now().Add(-time.Hour)); err != nil || !k.Validate() {
+		t.Errorf("Failure: reference an hour ago: %v", err)
+	}
+}
+
+func TestCurrentAndNext(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	// The RFC 6238 appendix B values for times 1111111109 and 1111111111,
+	// which are adjacent time-steps.
+	for _, at := range []time.Time{time.Unix(1111111109, 0), time.Unix(1111111109, 999999999), time.Unix(1111111080, 0)} {
+		cur, next, rollover := k.currentAndNextAt(at)
+		if cur != "07081804" || next != "14050471" || !rollover.Equal(time.Unix(1111111110, 0)) {
+			t.Errorf("Mismatch on time %v:\nWant: 07081804, 14050471, %d Got: %s, %s, %d", at, 1111111110, cur, next, rollover.Unix())
+		}
+	}
+
+	cur, next, rollover := k.CurrentAndNext()
+	if len(cur) != 8 || len(next) != 8 || cur == next || !rollover.After(time.Now().Add(-time.Second)) {
+		t.Errorf("Failure: got (%q, %q, %v)", cur, next, rollover)
+	}
+}
+
+func TestDomain(t *testing.T) {
+	// The RFC 4226 appendix D value for counter 1, and the values with the
+	// domain prepended to the counter, computed with Python's hmac.
+	w := []struct {
+		domain, expect string
+	}{
+		{"", "287082"},
+		{"login", "427871"},
+		{"signing", "914576"},
+	}
+	for _, v := range w {
+		k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 1, Domain: v.domain}
+		if otp := k.OTP(); otp != v.expect {
+			t.Errorf("Mismatch on domain %q:\nWant: %s Got: %s", v.domain, v.expect, otp)
+		}
+		tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, Domain: v.domain}
+		if otp := tk.OTPAt(time.Unix(59, 0)); otp != v.expect {
+			t.Errorf("Mismatch on TOTP domain %q:\nWant: %s Got: %s", v.domain, v.expect, otp)
+		}
+		if ok, _ := k.Verify(v.expect, 0); !ok {
+			t.Errorf("Failure: domain %q: own code rejected", v.domain)
+		}
+	}
+
+	a := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Domain: "login"}
+	b := a
+	b.Domain = "signing"
+	if a.SameParams(&b) {
+		t.Errorf("Failure: keys in different domains have the same parameters")
+	}
+	if ok, _ := b.Verify(a.OTP(), 0); ok {
+		t.Errorf("Failure: code of one domain accepted in another")
+	}
+}
+
+func TestAsTOTP(t *testing.T) {
+	hk := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA256, Digits: 8, Counter: 5, Domain: "login"}
+	tk, err := hk.AsTOTP(60, 100)
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	want := TOTPKey{SecretKey: hk.SecretKey, HashFunction: SHA256, Digits: 8, TimeStep: 60, T0: 100, Domain: "login"}
+	if *tk != want {
+		t.Fatalf("Mismatch on AsTOTP:\nWant: %+v Got: %+v", want, *tk)
+	}
+
+	// Converting back at a time yields the counter of its time-step, with
+	// the same OTPs.
+	for _, at := range []int64{100, 159, 160, 1111111109} {
+		c, err := tk.CounterAt(time.Unix(at, 0))
+		if err != nil {
+			t.Fatalf("Failure: time %d: %v", at, err)
+		}
+		if want := uint64(at-100) / 60; c != want {
+			t.Errorf("Mismatch on counter at time %d:\nWant: %d Got: %d", at, want, c)
+		}
+		back := tk.hotp(c)
+		hk.Counter = c
+		if back.StringWithSecret() != hk.StringWithSecret() || tk.OTPAt(time.Unix(at, 0)) != hk.OTP() {
+			t.Errorf("Mismatch on round trip at time %d:\nWant: %s Got: %s", at, hk.StringWithSecret(), back.StringWithSecret())
+		}
+	}
+
+	if _, err := hk.AsTOTP(0, 0); err != ErrInvalidTimeStep {
+		t.Errorf("Failure: time-step 0: got %v, want %v", err, ErrInvalidTimeStep)
+	}
+	o := 3
+	for _, k := range []HOTPKey{
+		{SecretKey: hk.SecretKey, HashFunction: SHA1, Digits: 6, TruncationOffset: &o},
+		{SecretKey: hk.SecretKey, HashFunction: SHA1, Digits: 6, Encoding: Hex},
+	} {
+		if _, err := k.AsTOTP(30, 0); err != ErrNoTOTPEquivalent {
+			t.Errorf("Failure: key %v: got %v, want %v", k, err, ErrNoTOTPEquivalent)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package otp
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Parses a number of digits, such as from a configuration file. It must be 1
+// through MaxDigits.
+func ParseDigits(s string) (byte, error) {
+	d, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("otp: malformed digits %q: %w", s, err)
+	}
+	if d == 0 {
+		return 0, fmt.Errorf("otp: digits %q: %w", s, ErrZeroDigits)
+	}
+	if d > MaxDigits {
+		return 0, fmt.Errorf("otp: digits %q: %w", s, ErrDigitsOutOfRange)
+	}
+	return byte(d), nil
+}
+
+// Parses a TOTP time-step, in seconds, such as from a configuration file. It
+// must be positive.
+func ParsePeriod(s string) (uint64, error) {
+	p, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("otp: malformed period %q: %w", s, err)
+	}
+	if p == 0 {
+		return 0, fmt.Errorf("otp: period %q: %w", s, ErrInvalidTimeStep)
+	}
+	return p, nil
+}
+
+// Returns the TOTPKey described by string-typed fields, such as from a
+// configuration file. Empty hash, digits, and period fields take the values
+// DefaultHash, DefaultDigits, and DefaultTimeStep. The returned error names
+// the offending field.
+func TOTPKeyFromStrings(secret, hash, digits, period string) (*TOTPKey, error) {
+	k := &TOTPKey{
+		SecretKey:    secret,
+		HashFunction: DefaultHash,
+		Digits:       DefaultDigits,
+		TimeStep:     DefaultTimeStep,
+	}
+	var err error
+	if hash != "" {
+		k.HashFunction = canonicalHash(HashFunction(hash))
+		if lookupHash(k.HashFunction) == nil {
+			return nil, fmt.Errorf("otp: hash %q: %w", hash, ErrUnknownHash)
+		}
+	}
+	if digits != "" {
+		if k.Digits, err = ParseDigits(digits); err != nil {
+			return nil, err
+		}
+	}
+	if period != "" {
+		if k.TimeStep, err = ParsePeriod(period); err != nil {
+			return nil, err
+		}
+	}
+	if err := k.ValidateDetailed(); err != nil {
+		return nil, fmt.Errorf("otp: secret: %w", err)
+	}
+	return k, nil
+}
@@ -0,0 +1,81 @@
+package otp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseDigits(t *testing.T) {
+	w := []struct {
+		s      string
+		expect byte
+		err    error
+	}{
+		{"6", 6, nil},
+		{"1", 1, nil},
+		{"10", 10, nil},
+		{"0", 0, ErrDigitsOutOfRange},
+		{"11", 0, ErrDigitsOutOfRange},
+	}
+	for _, v := range w {
+		d, err := ParseDigits(v.s)
+		if !errors.Is(err, v.err) || d != v.expect {
+			t.Errorf("Mismatch on %q\nWant: (%d, %v) Got: (%d, %v)", v.s, v.expect, v.err, d, err)
+		}
+	}
+	for _, s := range []string{"", "six", "-6", "256", " 6"} {
+		if d, err := ParseDigits(s); err == nil || !strings.Contains(err.Error(), "digits") {
+			t.Errorf("Failure: %q: got (%d, %v)", s, d, err)
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	if p, err := ParsePeriod("30"); err != nil || p != 30 {
+		t.Errorf("Mismatch on \"30\"\nWant: 30 Got: %d (%v)", p, err)
+	}
+	if _, err := ParsePeriod("0"); !errors.Is(err, ErrInvalidTimeStep) {
+		t.Errorf("Failure: \"0\": got %v, want %v", err, ErrInvalidTimeStep)
+	}
+	for _, s := range []string{"", "30s", "-30", "1.5"} {
+		if p, err := ParsePeriod(s); err == nil || !strings.Contains(err.Error(), "period") {
+			t.Errorf("Failure: %q: got (%d, %v)", s, p, err)
+		}
+	}
+}
+
+func TestTOTPKeyFromStrings(t *testing.T) {
+	const sk = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	k, err := TOTPKeyFromStrings(sk, "sha256", "8", "60")
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	want := TOTPKey{SecretKey: sk, HashFunction: SHA256, Digits: 8, TimeStep: 60}
+	if *k != want {
+		t.Errorf("Mismatch:\nWant: %+v Got: %+v", want, *k)
+	}
+	k, err = TOTPKeyFromStrings(sk, "", "", "")
+	want = TOTPKey{SecretKey: sk, HashFunction: DefaultHash, Digits: DefaultDigits, TimeStep: DefaultTimeStep}
+	if err != nil || *k != want {
+		t.Errorf("Mismatch on defaults:\nWant: %+v Got: %+v (%v)", want, k, err)
+	}
+
+	w := []struct {
+		secret, hash, digits, period string
+		field                        string
+		err                          error
+	}{
+		{sk, "MD4", "", "", "hash", ErrUnknownHash},
+		{sk, "", "12", "", "digits", ErrDigitsOutOfRange},
+		{sk, "", "", "0", "period", ErrInvalidTimeStep},
+		{"GEZDGNBV", "", "", "", "secret", ErrSecretTooShort},
+		{"NOTBASE32!", "", "", "", "secret", ErrBadBase32},
+	}
+	for _, v := range w {
+		_, err := TOTPKeyFromStrings(v.secret, v.hash, v.digits, v.period)
+		if !errors.Is(err, v.err) || !strings.Contains(err.Error(), v.field) {
+			t.Errorf("Failure on %+v: got error %v, want %v naming %s", v, err, v.err, v.field)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package otp
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// Returned by VerifyWithPolicy when the key's attempts are exhausted.
+	ErrLockedOut = errors.New("otp: too many failed attempts")
+	// Returned by VerifyWithPolicy for a code that was already consumed.
+	ErrReplayed = errors.New("otp: code was already used")
+	// Returned by VerifyWithPolicy for a Policy that forbids replays but has
+	// no ReplayGuard to detect them.
+	ErrNoReplayGuard = errors.New("otp: policy forbids replays but has no ReplayGuard")
+)
+
+// Bundles the rules by which VerifyWithPolicy verifies codes, so that they are
+// expressed in one place.
+type Policy struct {
+	// The number of time-steps tolerated on either side, as for Verify.
+	Skew uint
+	// Whether a code may be used more than once within its validity window.
+	// If false, Guard must be set.
+	AllowReplay bool
+	// Records consumed time-steps, if replays are forbidden.
+	Guard ReplayGuard
+	// If non-nil, limits the attempts per key.
+	Attempts *Attempts
+}
+
+// Returns a Policy that tolerates a skew of 1 time-step, forbids replays, and
+// allows 5 failed attempts per key every 15 minutes. Its guard and attempts
+// are in memory, and new on each call, so the Policy should be created once
+// and shared.
+func DefaultPolicy() Policy {
+	return Policy{
+		Skew:     1,
+		Guard:    NewMemoryReplayGuard(),
+		Attempts: NewAttempts(5, 15*time.Minute),
+	}
+}
+
+// Verifies code for the key identified by keyID according to p: if the key is
+// locked out, ErrLockedOut is returned without checking code, and if code
+// matches but was already consumed, ErrReplayed. An invalid receiver TOTPKey
+// yields the error of ValidateDetailed. A code that simply does not match
+// yields false and a nil error. A successful verification resets the
+// attempts. Consumed time-steps are remembered for as long as the key
+// accepts them, whatever its TimeStep, so one Policy can serve keys with
+// different time-steps.
+func (k *TOTPKey) VerifyWithPolicy(keyID, code string, p Policy) (bool, error) {
+	if !p.AllowReplay && p.Guard == nil {
+		return false, ErrNoReplayGuard
+	}
+	if err := k.ValidateDetailed(); err != nil {
+		return false, err
+	}
+	if p.Attempts != nil && !p.Attempts.Allow(keyID) {
+		return false, ErrLockedOut
+	}
+	ok, step := k.VerifyStep(code, p.Skew)
+	if !ok {
+		return false, nil
+	}
+	// The guard remembers the time-step for as long as the key accepts it.
+	if !p.AllowReplay && p.Guard.Seen(keyID, step, k.stepExpiry(step, p.Skew)) {
+		return false, ErrReplayed
+	}
+	if p.Attempts != nil {
+		p.Attempts.Reset(keyID)
+	}
+	return true, nil
+}
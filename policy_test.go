@@ -0,0 +1,77 @@
+package otp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyWithPolicy(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 3600}
+	p := DefaultPolicy()
+	p.Attempts.MaxFailures = 3
+
+	code := k.OTP()
+	if ok, err := k.VerifyWithPolicy("alice", code, p); !ok || err != nil {
+		t.Fatalf("Failure: current code: got (%v, %v)", ok, err)
+	}
+	if ok, err := k.VerifyWithPolicy("alice", code, p); ok || err != ErrReplayed {
+		t.Errorf("Failure: replayed code: got (%v, %v), want (false, %v)", ok, err, ErrReplayed)
+	}
+	// Replays are per key.
+	if ok, err := k.VerifyWithPolicy("bob", code, p); !ok || err != nil {
+		t.Errorf("Failure: code for another key: got (%v, %v)", ok, err)
+	}
+
+	// The replay counts as a failure, as do wrong codes, until lockout.
+	for i := 0; i < 2; i++ {
+		if ok, err := k.VerifyWithPolicy("alice", "00000000", p); ok || err != nil {
+			t.Errorf("Failure: wrong code %d: got (%v, %v), want (false, nil)", i, ok, err)
+		}
+	}
+	if ok, err := k.VerifyWithPolicy("alice", k.OTPAt(time.Now().Add(time.Hour)), p); ok || err != ErrLockedOut {
+		t.Errorf("Failure: locked out: got (%v, %v), want (false, %v)", ok, err, ErrLockedOut)
+	}
+
+	// Replays may be allowed, with no guard.
+	p = Policy{Skew: 1, AllowReplay: true}
+	for i := 0; i < 2; i++ {
+		if ok, err := k.VerifyWithPolicy("alice", code, p); !ok || err != nil {
+			t.Errorf("Failure: allowed replay %d: got (%v, %v)", i, ok, err)
+		}
+	}
+	if ok, err := k.VerifyWithPolicy("alice", code, Policy{}); ok || err != ErrNoReplayGuard {
+		t.Errorf("Failure: no guard: got (%v, %v), want (false, %v)", ok, err, ErrNoReplayGuard)
+	}
+	bad := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, TimeStep: 30}
+	if ok, err := bad.VerifyWithPolicy("alice", code, p); ok || err != ErrZeroDigits {
+		t.Errorf("Failure: invalid key: got (%v, %v), want (false, %v)", ok, err, ErrZeroDigits)
+	}
+}
+
+func TestVerifyWithPolicyLongStep(t *testing.T) {
+	// A code of an hour-long time-step is accepted for up to 3 hours with a
+	// skew of 1, and must be remembered for as long.
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 3600}
+	p := DefaultPolicy()
+	g := p.Guard.(*MemoryReplayGuard)
+	code := k.OTP()
+	if ok, err := k.VerifyWithPolicy("alice", code, p); !ok || err != nil {
+		t.Fatalf("Failure: current code: got (%v, %v)", ok, err)
+	}
+	later := time.Now().Add(59 * time.Minute)
+	g.now = func() time.Time { return later }
+	if ok, err := k.VerifyWithPolicy("alice", code, p); ok || err != ErrReplayed {
+		t.Errorf("Failure: replay an hour later: got (%v, %v), want (false, %v)", ok, err, ErrReplayed)
+	}
+	ctr, _ := k.CounterAt(time.Now())
+	if exp := k.stepExpiry(ctr, p.Skew); exp.Sub(time.Now()) <= time.Hour {
+		t.Errorf("Failure: step remembered only until %v", exp)
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	p := DefaultPolicy()
+	if p.Skew != 1 || p.AllowReplay || p.Guard == nil || p.Attempts == nil {
+		t.Fatalf("Failure: unexpected default policy %+v", p)
+	}
+}
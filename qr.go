@@ -0,0 +1,80 @@
+package otp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Encodes content as a QR code image, such as a PNG, of size by size pixels,
+// and writes it to w. This keeps any QR library out of the package's
+// dependencies; for example, an implementation using
+// github.com/skip2/go-qrcode is
+//
+//	func(w io.Writer, content string, size int) error {
+//		png, err := qrcode.Encode(content, qrcode.Medium, size)
+//		if err != nil {
+//			return err
+//		}
+//		_, err = w.Write(png)
+//		return err
+//	}
+type QREncoder func(w io.Writer, content string, size int) error
+
+var ErrNoQREncoder = errors.New("otp: no QR encoder")
+
+// Returned by WriteQRCode and QRCode for keys with a Domain or ClockOffset,
+// which provisioning URIs cannot express, so that an authenticator enrolled
+// from them would never produce matching codes.
+var ErrNotProvisionable = errors.New("otp: key has a Domain or ClockOffset, which URIs cannot express")
+
+// Writes a QR code of the provisioning URI of the TOTP parameter-set to w, for
+// enrolment by scanning it into an authenticator. ErrNotProvisionable is
+// returned if the key has a Domain or ClockOffset.
+func (k *TOTPKey) WriteQRCode(w io.Writer, issuer, account string, size int,
+	enc QREncoder) error {
+	if k.Domain != "" || k.ClockOffset != 0 {
+		return ErrNotProvisionable
+	}
+	return writeQRCode(w, k.URI(issuer, account), size, enc)
+}
+
+// Returns a QR code of the provisioning URI of the TOTP parameter-set, in the
+// image format produced by enc.
+func (k *TOTPKey) QRCode(issuer, account string, size int,
+	enc QREncoder) ([]byte, error) {
+	var b bytes.Buffer
+	if err := k.WriteQRCode(&b, issuer, account, size, enc); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Writes a QR code of the provisioning URI of the HOTP parameter-set to w, for
+// enrolment by scanning it into an authenticator. ErrNotProvisionable is
+// returned if the key has a Domain.
+func (k *HOTPKey) WriteQRCode(w io.Writer, issuer, account string, size int,
+	enc QREncoder) error {
+	if k.Domain != "" {
+		return ErrNotProvisionable
+	}
+	return writeQRCode(w, k.URI(issuer, account), size, enc)
+}
+
+// Returns a QR code of the provisioning URI of the HOTP parameter-set, in the
+// image format produced by enc.
+func (k *HOTPKey) QRCode(issuer, account string, size int,
+	enc QREncoder) ([]byte, error) {
+	var b bytes.Buffer
+	if err := k.WriteQRCode(&b, issuer, account, size, enc); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeQRCode(w io.Writer, uri string, size int, enc QREncoder) error {
+	if enc == nil {
+		return ErrNoQREncoder
+	}
+	return enc(w, uri, size)
+}
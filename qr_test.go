@@ -0,0 +1,53 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestQRCode(t *testing.T) {
+	// Stands in for a QR library by writing its arguments.
+	enc := func(w io.Writer, content string, size int) error {
+		_, err := fmt.Fprintf(w, "%d:%s", size, content)
+		return err
+	}
+
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	b, err := tk.QRCode("ACME", "alice", 256, enc)
+	if want := "256:" + tk.URI("ACME", "alice"); err != nil || string(b) != want {
+		t.Errorf("Mismatch:\nWant: %s Got: %s (%v)", want, b, err)
+	}
+	hk := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}
+	b, err = hk.QRCode("ACME", "bob", 128, enc)
+	if want := "128:" + hk.URI("ACME", "bob"); err != nil || string(b) != want {
+		t.Errorf("Mismatch:\nWant: %s Got: %s (%v)", want, b, err)
+	}
+
+	if _, err := tk.QRCode("ACME", "alice", 256, nil); err != ErrNoQREncoder {
+		t.Errorf("Failure: nil encoder: got error %v, want %v", err, ErrNoQREncoder)
+	}
+	failing := errors.New("encoding failed")
+	_, err = tk.QRCode("ACME", "alice", 256, func(io.Writer, string, int) error { return failing })
+	if err != failing {
+		t.Errorf("Failure: encoder error: got %v, want %v", err, failing)
+	}
+
+	// Authenticators cannot be enrolled with a Domain or ClockOffset.
+	dk := tk
+	dk.Domain = "login"
+	if _, err := dk.QRCode("ACME", "alice", 256, enc); err != ErrNotProvisionable {
+		t.Errorf("Failure: TOTP Domain: got error %v, want %v", err, ErrNotProvisionable)
+	}
+	ck := tk
+	ck.ClockOffset = time.Minute
+	if _, err := ck.QRCode("ACME", "alice", 256, enc); err != ErrNotProvisionable {
+		t.Errorf("Failure: ClockOffset: got error %v, want %v", err, ErrNotProvisionable)
+	}
+	hk.Domain = "login"
+	if _, err := hk.QRCode("ACME", "bob", 128, enc); err != ErrNotProvisionable {
+		t.Errorf("Failure: HOTP Domain: got error %v, want %v", err, ErrNotProvisionable)
+	}
+}
@@ -0,0 +1,30 @@
+package otp
+
+import "strings"
+
+// The secret-key seed of the RFC 4226 and RFC 6238 test vectors: the ASCII
+// digits "1234567890", repeated.
+const referenceSeed = "1234567890"
+
+// Returns the OTP for counter under the test vectors' parameter-set, for
+// checking a build against the RFCs' tables. The secret-key is that of the
+// tables for hf: "12345678901234567890" for SHA1, as in RFC 4226 appendix D
+// and RFC 6238 appendix B, and the same digits repeated to 32 and 64 bytes for
+// SHA256 and SHA512, as in RFC 6238 appendix B. Other hash functions use the
+// length of their digest. The counters of the RFC 6238 table are its "T"
+// values. If the parameter-set is invalid, such as when hf is unknown,
+// OnInvalidKey is called.
+func ReferenceVector(hf HashFunction, digits byte, counter uint64) string {
+	n := MinKeySizeFor(hf)
+	if n == 0 {
+		invalidKey(ErrUnknownHash)
+		return ""
+	}
+	k := HOTPKey{
+		SecretKey:      strings.Repeat(referenceSeed, (n+len(referenceSeed)-1)/len(referenceSeed))[:n],
+		SecretEncoding: EncodingRaw,
+		HashFunction:   hf,
+		Digits:         digits,
+	}
+	return k.OTPAt(counter)
+}
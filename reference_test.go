@@ -0,0 +1,55 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReferenceVector(t *testing.T) {
+	w := []struct {
+		hf      HashFunction
+		digits  byte
+		counter uint64
+		expect  string
+	}{
+		// RFC 4226 appendix D.
+		{SHA1, 6, 0, "755224"},
+		{SHA1, 6, 1, "287082"},
+		{SHA1, 6, 2, "359152"},
+		{SHA1, 6, 3, "969429"},
+		{SHA1, 6, 4, "338314"},
+		{SHA1, 6, 5, "254676"},
+		{SHA1, 6, 6, "287922"},
+		{SHA1, 6, 7, "162583"},
+		{SHA1, 6, 8, "399871"},
+		{SHA1, 6, 9, "520489"},
+		// RFC 6238 appendix B, by their "T" values.
+		{SHA1, 8, 0x1, "94287082"},
+		{SHA256, 8, 0x1, "46119246"},
+		{SHA512, 8, 0x1, "90693936"},
+		{SHA1, 8, 0x23523EC, "07081804"},
+		{SHA256, 8, 0x23523EC, "68084774"},
+		{SHA512, 8, 0x23523EC, "25091201"},
+		{SHA1, 8, 0x27BC86AA, "65353130"},
+		{SHA256, 8, 0x27BC86AA, "77737706"},
+		{SHA512, 8, 0x27BC86AA, "47863826"},
+	}
+	for _, v := range w {
+		if got := ReferenceVector(v.hf, v.digits, v.counter); got != v.expect {
+			t.Errorf("Mismatch on %s, %d digits, counter %d:\nWant: %s Got: %s", v.hf, v.digits, v.counter, v.expect, got)
+		}
+	}
+
+	defer func(f func(error)) { OnInvalidKey = f }(OnInvalidKey)
+	var got []error
+	OnInvalidKey = func(err error) { got = append(got, err) }
+	if otp := ReferenceVector("MD4", 6, 0); otp != "" {
+		t.Errorf("Failure: unknown hash yielded %q", otp)
+	}
+	if otp := ReferenceVector(SHA1, 0, 0); otp != "" {
+		t.Errorf("Failure: 0 digits yielded %q", otp)
+	}
+	if len(got) != 2 || got[0] != ErrUnknownHash || !errors.Is(got[1], ErrZeroDigits) {
+		t.Errorf("Failure: OnInvalidKey called with %v", got)
+	}
+}
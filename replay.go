@@ -0,0 +1,99 @@
+package otp
+
+import (
+	"math"
+	"math/bits"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Records consumed time-steps, so that an intercepted OTP cannot be replayed
+// while it is still within its validity window. Time-steps, rather than codes,
+// are recorded, so that the same code recurring in a later time-step is not
+// mistaken for a replay. It is small so that a shared store, such as Redis,
+// can implement it.
+type ReplayGuard interface {
+	// Reports whether the time-step step was already consumed for keyID, and
+	// records it as consumed. Its codes are no longer accepted from expires
+	// on, after which the record may be forgotten.
+	Seen(keyID string, step uint64, expires time.Time) bool
+}
+
+// An in-memory ReplayGuard, safe for concurrent use. Time-steps are forgotten
+// once they expire. The zero value is ready for use.
+type MemoryReplayGuard struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// The earliest expiry in seen, before which nothing needs sweeping.
+	nextSweep time.Time
+	// Overridable for testing.
+	now func() time.Time
+}
+
+// Returns an empty MemoryReplayGuard.
+func NewMemoryReplayGuard() *MemoryReplayGuard {
+	return &MemoryReplayGuard{}
+}
+
+// Reports whether step was already consumed for keyID, and records it as
+// consumed until expires.
+func (g *MemoryReplayGuard) Seen(keyID string, step uint64, expires time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	if g.now != nil {
+		now = g.now()
+	}
+	if g.seen == nil {
+		g.seen = make(map[string]time.Time)
+	}
+	// Sweeping only once the earliest record expires bounds the map to the
+	// unexpired time-steps, without scanning it on every call.
+	if len(g.seen) > 0 && !now.Before(g.nextSweep) {
+		g.nextSweep = time.Time{}
+		for id, exp := range g.seen {
+			if !now.Before(exp) {
+				delete(g.seen, id)
+			} else if g.nextSweep.IsZero() || exp.Before(g.nextSweep) {
+				g.nextSweep = exp
+			}
+		}
+	}
+	id := keyID + "\x00" + strconv.FormatUint(step, 10)
+	if exp, ok := g.seen[id]; ok && now.Before(exp) {
+		return true
+	}
+	if !now.Before(expires) {
+		// Already expired, so there is nothing to remember.
+		return false
+	}
+	g.seen[id] = expires
+	if len(g.seen) == 1 || expires.Before(g.nextSweep) {
+		g.nextSweep = expires
+	}
+	return false
+}
+
+// Like Verify, but additionally rejects codes of time-steps that g reports as
+// already consumed for keyID. A time-step is only recorded as consumed once a
+// code matches it, and is remembered for as long as its codes are accepted
+// with skew.
+func (k *TOTPKey) VerifyWithGuard(keyID, code string, skew uint,
+	g ReplayGuard) bool {
+	ok, step := k.VerifyStep(code, skew)
+	return ok && !g.Seen(keyID, step, k.stepExpiry(step, skew))
+}
+
+// Returns the time from which codes of the time-step step are no longer
+// accepted with skew: the end of the time-step skew after it.
+func (k *TOTPKey) stepExpiry(step uint64, skew uint) time.Time {
+	end := step + clampSkew(skew) + 1
+	hi, lo := bits.Mul64(end, k.TimeStep)
+	lo, carry := bits.Add64(lo, k.T0, 0)
+	if end <= step || hi != 0 || carry != 0 || lo > math.MaxInt64/2 {
+		// Never, for practical purposes.
+		return time.Unix(math.MaxInt64/2, 0)
+	}
+	return time.Unix(int64(lo), 0).Add(-k.ClockOffset)
+}
@@ -0,0 +1,98 @@
+package otp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryReplayGuard(t *testing.T) {
+	now := time.Unix(1000, 0)
+	g := NewMemoryReplayGuard()
+	g.now = func() time.Time { return now }
+	exp := now.Add(90 * time.Second)
+
+	if g.Seen("alice", 33, exp) {
+		t.Errorf("Failure: fresh step reported as seen")
+	}
+	if !g.Seen("alice", 33, exp) {
+		t.Errorf("Failure: replayed step reported as unseen")
+	}
+	if g.Seen("bob", 33, exp) || g.Seen("alice", 34, exp) {
+		t.Errorf("Failure: distinct key or step reported as seen")
+	}
+
+	now = now.Add(89 * time.Second)
+	if !g.Seen("alice", 33, exp) {
+		t.Errorf("Failure: step forgotten before it expired")
+	}
+	now = now.Add(time.Second)
+	if g.Seen("alice", 33, exp) {
+		t.Errorf("Failure: step remembered after it expired")
+	}
+	if len(g.seen) != 0 {
+		t.Errorf("Failure: %d entries remain after expiry, want 0", len(g.seen))
+	}
+
+	// Entries are swept as they expire, each at its own time.
+	g.Seen("carol", 40, now.Add(30*time.Second))
+	g.Seen("carol", 41, now.Add(60*time.Second))
+	now = now.Add(30 * time.Second)
+	g.Seen("dave", 1, now.Add(time.Hour))
+	if len(g.seen) != 2 {
+		t.Errorf("Failure: %d entries remain after an expiry, want 2", len(g.seen))
+	}
+	if !g.Seen("carol", 41, now.Add(30*time.Second)) {
+		t.Errorf("Failure: unexpired step forgotten")
+	}
+}
+
+func TestVerifyWithGuard(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	g := NewMemoryReplayGuard()
+	code := k.OTP()
+	if k.VerifyWithGuard("alice", "", 1, g) {
+		t.Errorf("Failure: malformed code accepted")
+	}
+	if !k.VerifyWithGuard("alice", code, 1, g) {
+		t.Errorf("Failure: fresh code rejected")
+	}
+	if k.VerifyWithGuard("alice", code, 1, g) {
+		t.Errorf("Failure: replayed code accepted")
+	}
+	if !k.VerifyWithGuard("bob", code, 1, g) {
+		t.Errorf("Failure: code rejected for a different key")
+	}
+}
+
+func TestStepExpiry(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, T0: 10}
+	// Step 2 is [70, 100); with a skew of 1, it is accepted until 130.
+	w := []struct {
+		step   uint64
+		skew   uint
+		offset time.Duration
+		expect int64
+	}{
+		{2, 0, 0, 100},
+		{2, 1, 0, 130},
+		{2, 1, 5 * time.Second, 125},
+		{2, 1, -5 * time.Second, 135},
+		{0, 2, 0, 100},
+	}
+	for _, v := range w {
+		k.ClockOffset = v.offset
+		if got := k.stepExpiry(v.step, v.skew); got.Unix() != v.expect {
+			t.Errorf("Mismatch on step %d, skew %d, offset %v\nWant: %d Got: %d", v.step, v.skew, v.offset, v.expect, got.Unix())
+		}
+	}
+
+	// Overflowing expiries are in the far future.
+	k.ClockOffset = 0
+	far := time.Now().AddDate(1000, 0, 0)
+	if got := k.stepExpiry(1<<63, 0); !got.After(far) {
+		t.Errorf("Failure: overflowing expiry is %v", got)
+	}
+	if got := k.stepExpiry(1<<64-1, 1); !got.After(far) {
+		t.Errorf("Failure: wrapping expiry is %v", got)
+	}
+}
@@ -0,0 +1,175 @@
+package otp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Encrypts secret-keys for storage, such as with AES-GCM or a KMS.
+type Sealer interface {
+	Seal(plaintext []byte) ([]byte, error)
+}
+
+// Decrypts secret-keys sealed by the corresponding Sealer.
+type Unsealer interface {
+	Open(ciphertext []byte) ([]byte, error)
+}
+
+var (
+	// Returned when a sealed secret-key is too short to have been sealed by an
+	// AESGCMSealer.
+	ErrMalformedSealed = errors.New("otp: malformed sealed secret-key")
+)
+
+// The alias types have no methods, so marshalling them does not recurse.
+type hotpFields HOTPKey
+type totpFields TOTPKey
+
+// The JSON form of SealedJSON. The always nil SecretKey shadows the embedded
+// one, so that the plaintext secret-key is never encoded.
+type sealedHOTPKey struct {
+	hotpFields
+	SecretKey *struct{} `json:"secret_key,omitempty"`
+	Sealed    []byte    `json:"sealed_secret_key"`
+}
+
+type sealedTOTPKey struct {
+	totpFields
+	SecretKey *struct{} `json:"secret_key,omitempty"`
+	Sealed    []byte    `json:"sealed_secret_key"`
+}
+
+// Encodes the HOTP parameter-set as JSON, like json.Marshal, but with the
+// secret-key sealed by s, under "sealed_secret_key" in place of "secret_key".
+// The secret-key is sealed as stored, in its SecretEncoding. Use OpenHOTP to
+// decode the result.
+func (k *HOTPKey) SealedJSON(s Sealer) ([]byte, error) {
+	sk, err := s.Seal([]byte(k.SecretKey))
+	if err != nil {
+		return nil, fmt.Errorf("otp: sealing secret-key: %w", err)
+	}
+	return json.Marshal(sealedHOTPKey{hotpFields: hotpFields(*k), Sealed: sk})
+}
+
+// Encodes the TOTP parameter-set as JSON with its secret-key sealed by s. See
+// HOTPKey.SealedJSON.
+func (k *TOTPKey) SealedJSON(s Sealer) ([]byte, error) {
+	sk, err := s.Seal([]byte(k.SecretKey))
+	if err != nil {
+		return nil, fmt.Errorf("otp: sealing secret-key: %w", err)
+	}
+	return json.Marshal(sealedTOTPKey{totpFields: totpFields(*k), Sealed: sk})
+}
+
+// Decodes an HOTP parameter-set encoded by HOTPKey.SealedJSON, opening its
+// secret-key with u, and validates it as UnmarshalJSON does. For keys that
+// need the settings of Set methods, such as SetBase32Encoding, to validate,
+// use HOTPKey.UnmarshalSealedJSON instead.
+func OpenHOTP(data []byte, u Unsealer) (*HOTPKey, error) {
+	k := new(HOTPKey)
+	if err := k.UnmarshalSealedJSON(data, u); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Like OpenHOTP, but decodes into the receiver HOTPKey, keeping the settings
+// of its Set methods, as UnmarshalJSON does. On error, the receiver is left
+// unchanged.
+func (k *HOTPKey) UnmarshalSealedJSON(data []byte, u Unsealer) error {
+	var v sealedHOTPKey
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	sk, err := u.Open(v.Sealed)
+	if err != nil {
+		return fmt.Errorf("otp: opening secret-key: %w", err)
+	}
+	h := HOTPKey(v.hotpFields)
+	h.copySettings(k)
+	h.SecretKey = string(sk)
+	h.HashFunction = canonicalHash(h.HashFunction)
+	if !h.Validate() {
+		return ErrInvalidHOTPKey
+	}
+	*k = h
+	return nil
+}
+
+// Decodes a TOTP parameter-set encoded by TOTPKey.SealedJSON, opening its
+// secret-key with u, and validates it as UnmarshalJSON does. For keys that
+// need the settings of Set methods to validate, use
+// TOTPKey.UnmarshalSealedJSON instead.
+func OpenTOTP(data []byte, u Unsealer) (*TOTPKey, error) {
+	k := new(TOTPKey)
+	if err := k.UnmarshalSealedJSON(data, u); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Like OpenTOTP, but decodes into the receiver TOTPKey, keeping the settings
+// of its Set methods. See HOTPKey.UnmarshalSealedJSON.
+func (k *TOTPKey) UnmarshalSealedJSON(data []byte, u Unsealer) error {
+	var v sealedTOTPKey
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	sk, err := u.Open(v.Sealed)
+	if err != nil {
+		return fmt.Errorf("otp: opening secret-key: %w", err)
+	}
+	t := TOTPKey(v.totpFields)
+	t.copySettings(k)
+	t.SecretKey = string(sk)
+	t.HashFunction = canonicalHash(t.HashFunction)
+	if !t.Validate() {
+		return ErrInvalidTOTPKey
+	}
+	*k = t
+	return nil
+}
+
+// A reference Sealer and Unsealer using AES-GCM. Each sealed secret-key is
+// prefixed with its randomly generated nonce.
+type AESGCMSealer struct {
+	aead cipher.AEAD
+}
+
+// Returns an AESGCMSealer for the AES key key, which must be 16, 24, or 32
+// bytes long, selecting AES-128, AES-192, or AES-256.
+func NewAESGCMSealer(key []byte) (*AESGCMSealer, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("otp: %w", err)
+	}
+	aead, err := cipher.NewGCM(b)
+	if err != nil {
+		return nil, fmt.Errorf("otp: %w", err)
+	}
+	return &AESGCMSealer{aead: aead}, nil
+}
+
+// Encrypts plaintext under a fresh nonce, and returns the nonce followed by
+// the ciphertext.
+func (s *AESGCMSealer) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(plaintext)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("otp: reading random nonce: %w", err)
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypts and authenticates ciphertext sealed by Seal. ErrMalformedSealed is
+// returned if it is too short to hold a nonce.
+func (s *AESGCMSealer) Open(ciphertext []byte) ([]byte, error) {
+	n := s.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, ErrMalformedSealed
+	}
+	return s.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
@@ -0,0 +1,121 @@
+package otp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSealedJSON(t *testing.T) {
+	s, err := NewAESGCMSealer(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+
+	o := 3
+	hk := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 9, TruncationOffset: &o}
+	data, err := hk.SealedJSON(s)
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if bytes.Contains(data, []byte(hk.SecretKey)) || bytes.Contains(data, []byte(`"secret_key"`)) {
+		t.Errorf("Failure: plaintext secret-key in %s", data)
+	}
+	gotH, err := OpenHOTP(data, s)
+	if err != nil {
+		t.Fatalf("Failure: %s: %v", data, err)
+	}
+	if gotH.SecretKey != hk.SecretKey || gotH.Counter != 9 || *gotH.TruncationOffset != 3 || gotH.OTP() != hk.OTP() {
+		t.Errorf("Mismatch on round trip:\nWant: %+v Got: %+v", hk, gotH)
+	}
+
+	tk := TOTPKey{SecretKey: "12345678901234567890", SecretEncoding: EncodingRaw, HashFunction: SHA256, Digits: 8, TimeStep: 60, T0: 5}
+	data, err = tk.SealedJSON(s)
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if bytes.Contains(data, []byte(tk.SecretKey)) {
+		t.Errorf("Failure: plaintext secret-key in %s", data)
+	}
+	gotT, err := OpenTOTP(data, s)
+	if err != nil || *gotT != tk {
+		t.Errorf("Mismatch on round trip:\nWant: %+v Got: %+v (%v)", tk, gotT, err)
+	}
+
+	// Another key cannot open it.
+	other, _ := NewAESGCMSealer(bytes.Repeat([]byte{8}, 32))
+	if _, err := OpenTOTP(data, other); err == nil {
+		t.Errorf("Failure: opened with the wrong key")
+	}
+
+	// A sealed, invalid parameter-set is rejected once opened.
+	bad := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}
+	data, _ = bad.SealedJSON(s)
+	if _, err := OpenTOTP(data, s); err != ErrInvalidTOTPKey {
+		t.Errorf("Failure: got error %v, want %v", err, ErrInvalidTOTPKey)
+	}
+}
+
+func TestAESGCMSealer(t *testing.T) {
+	for _, n := range []int{16, 24, 32} {
+		s, err := NewAESGCMSealer(make([]byte, n))
+		if err != nil {
+			t.Fatalf("Failure: %d-byte key: %v", n, err)
+		}
+		a, _ := s.Seal([]byte("secret"))
+		b, _ := s.Seal([]byte("secret"))
+		if bytes.Equal(a, b) {
+			t.Errorf("Failure: %d-byte key: nonce reused", n)
+		}
+		if p, err := s.Open(a); err != nil || string(p) != "secret" {
+			t.Errorf("Mismatch on %d-byte key:\nWant: %s Got: %s (%v)", n, "secret", p, err)
+		}
+		a[len(a)-1] ^= 1
+		if _, err := s.Open(a); err == nil {
+			t.Errorf("Failure: %d-byte key: tampered ciphertext opened", n)
+		}
+		if _, err := s.Open(a[:5]); !errors.Is(err, ErrMalformedSealed) {
+			t.Errorf("Failure: %d-byte key: got error %v, want %v", n, err, ErrMalformedSealed)
+		}
+	}
+	if _, err := NewAESGCMSealer(make([]byte, 10)); err == nil {
+		t.Errorf("Failure: 10-byte key accepted")
+	}
+}
+
+func TestUnmarshalSealedJSONKeepsSettings(t *testing.T) {
+	s, _ := NewAESGCMSealer(bytes.Repeat([]byte{7}, 32))
+	hk := reversedAlphabetKey()
+	data, err := hk.SealedJSON(s)
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	var got HOTPKey
+	got.SetBase32Encoding(reversedBase32)
+	if err := got.UnmarshalSealedJSON(data, s); err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if otp := got.OTP(); otp != "94287082" {
+		t.Errorf("Mismatch on custom alphabet\nWant: %s Got: %s", "94287082", otp)
+	}
+
+	tk := TOTPKey{SecretKey: hk.SecretKey, HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	data, _ = tk.SealedJSON(s)
+	var gotT TOTPKey
+	gotT.SetBase32Encoding(reversedBase32)
+	if err := gotT.UnmarshalSealedJSON(data, s); err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if otp := gotT.OTPAt(time.Unix(59, 0)); otp != "94287082" {
+		t.Errorf("Mismatch on custom alphabet\nWant: %s Got: %s", "94287082", otp)
+	}
+
+	// On error, the receiver is left unchanged.
+	bad := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}
+	data, _ = bad.SealedJSON(s)
+	before := gotT
+	if err := gotT.UnmarshalSealedJSON(data, s); err != ErrInvalidTOTPKey || gotT != before {
+		t.Errorf("Failure: got error %v and key %+v", err, gotT)
+	}
+}
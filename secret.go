@@ -0,0 +1,370 @@
+package otp
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Generates a secret-key of the given number of bytes using crypto/rand, and
+// returns it base-32 encoded. Sizes below MinKeySize are rejected.
+func GenerateSecret(bytes int) (string, error) {
+	if bytes < MinKeySize {
+		return "", fmt.Errorf("otp: secret size %d is below MinKeySize", bytes)
+	}
+	b := make([]byte, bytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("otp: reading random secret: %w", err)
+	}
+	return base32.StdEncoding.EncodeToString(b), nil
+}
+
+// Generates a secret-key of the length recommended for the hash function hf,
+// as by MinKeySizeFor, and returns it base-32 encoded.
+func GenerateSecretFor(hf HashFunction) (string, error) {
+	n := MinKeySizeFor(hf)
+	if n == 0 {
+		return "", ErrUnknownHash
+	}
+	return GenerateSecret(n)
+}
+
+// Returns the secret-key length, in bytes, that RFC 6238 recommends for the
+// key's hash function: the size of its digest (20 bytes for SHA1, 32 for
+// SHA256, and 64 for SHA512). Zero is returned for an unknown hash function.
+func (k *HOTPKey) RecommendedSecretLen() int {
+	return MinKeySizeFor(k.HashFunction)
+}
+
+// Returns the secret-key length, in bytes, that RFC 6238 recommends for the
+// key's hash function. See HOTPKey.RecommendedSecretLen.
+func (k *TOTPKey) RecommendedSecretLen() int {
+	return MinKeySizeFor(k.HashFunction)
+}
+
+// Returns the secret-key size, in bytes, that RFC 6238 recommends as the
+// minimum for the hash function hf: the size of its digest, but no less than
+// MinKeySize. Zero is returned for an unknown hash function. Validate only
+// enforces MinKeySize, since shorter secret-keys are widespread; use
+// SecretSizeWarning to flag them.
+func MinKeySizeFor(hf HashFunction) int {
+	h := lookupHash(hf)
+	if h == nil {
+		return 0
+	}
+	n := h().Size()
+	if n < MinKeySize {
+		n = MinKeySize
+	}
+	return n
+}
+
+// Returned by SecretSizeWarning for secret-keys shorter than RFC 6238
+// recommends for their hash function. Such keys are valid, but weaker than the
+// hash function allows.
+var ErrSecretBelowRecommended = errors.New("otp: secret-key is shorter than recommended for the hash function")
+
+// Returns ErrSecretBelowRecommended if the secret-key is shorter than
+// MinKeySizeFor the key's hash function, and nil otherwise, or if the key is
+// invalid. Unlike ValidateDetailed, this is only a warning.
+func (k *HOTPKey) SecretSizeWarning() error {
+	sk, err := k.validate()
+	if err != nil {
+		return nil
+	}
+	if len(sk) < MinKeySizeFor(k.HashFunction) {
+		return ErrSecretBelowRecommended
+	}
+	return nil
+}
+
+// Returns ErrSecretBelowRecommended if the secret-key is shorter than
+// recommended for the key's hash function. See HOTPKey.SecretSizeWarning.
+func (k *TOTPKey) SecretSizeWarning() error {
+	return k.hotp(0).SecretSizeWarning()
+}
+
+// The fewest distinct byte values that SecretStrengthOK accepts in a
+// secret-key. A random secret-key of MinKeySize bytes has fewer with negligible
+// probability.
+const minDistinctBytes = 8
+
+// Reports whether the secret-key appears to be strong enough for production
+// use: it must decode, and have at least 8 distinct byte values, which rejects
+// placeholders such as all-zero or repeating secret-keys. This is a heuristic
+// and intentionally separate from Validate; a key passing it is not
+// necessarily strong.
+func (k *HOTPKey) SecretStrengthOK() bool {
+	sk, err := k.secret()
+	if err != nil {
+		return false
+	}
+	var seen [256]bool
+	n := 0
+	for _, b := range sk {
+		if !seen[b] {
+			seen[b] = true
+			n++
+		}
+	}
+	return n >= minDistinctBytes
+}
+
+// Reports whether the secret-key appears to be strong enough for production
+// use. See HOTPKey.SecretStrengthOK.
+func (k *TOTPKey) SecretStrengthOK() bool {
+	return k.hotp(0).SecretStrengthOK()
+}
+
+// Returns a TOTPKey with a freshly generated secret-key of DefaultSecretSize
+// bytes, and the default hash function, digits, and time-step.
+func NewTOTPKey() (*TOTPKey, error) {
+	sk, err := GenerateSecret(DefaultSecretSize)
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPKey{
+		SecretKey:    sk,
+		HashFunction: DefaultHash,
+		Digits:       DefaultDigits,
+		TimeStep:     DefaultTimeStep,
+	}, nil
+}
+
+// Returns a TOTPKey like NewTOTPKey, but with the time-step step. Since
+// TimeStep is in seconds, step must be a positive, whole number of seconds:
+// otherwise, ErrInvalidTimeStep or ErrStepNotSeconds is returned.
+func NewTOTPKeyWithStep(step time.Duration) (*TOTPKey, error) {
+	if step <= 0 {
+		return nil, ErrInvalidTimeStep
+	}
+	if step%time.Second != 0 {
+		return nil, ErrStepNotSeconds
+	}
+	k, err := NewTOTPKey()
+	if err != nil {
+		return nil, err
+	}
+	k.TimeStep = uint64(step / time.Second)
+	return k, nil
+}
+
+// Returns a TOTPKey with the parameter-set of many 8-digit enterprise tokens:
+// SHA256, 8 digits, and a time-step of 60 seconds, with a freshly generated
+// secret-key of the length recommended for SHA256.
+func NewEnterpriseTOTPKey() (*TOTPKey, error) {
+	sk, err := GenerateSecretFor(SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPKey{
+		SecretKey:    sk,
+		HashFunction: SHA256,
+		Digits:       8,
+		TimeStep:     60,
+	}, nil
+}
+
+// Specifies how a secret-key is encoded.
+type SecretEncoding byte
+
+const (
+	// Base-32, using the standard alphabet of RFC 4648, section 6.
+	EncodingStd SecretEncoding = iota
+	// Base-32, using the extended hex alphabet of RFC 4648, section 7,
+	// "0123456789ABCDEFGHIJKLMNOPQRSTUV". Despite its name, this is not
+	// hexadecimal; use EncodingBase16 for that.
+	EncodingHex
+	// No encoding: the secret-key holds the raw bytes.
+	EncodingRaw
+	// Hexadecimal (base-16), as many hardware tokens' seeds are supplied, in
+	// either case.
+	EncodingBase16
+)
+
+// Returns an HOTPKey for the base-32 encoded secret-key, or the error of
+// ValidateDetailed if the parameter-set is invalid. Since the key is validated
+// up front, its OTP method cannot panic; this is the preferred way to
+// construct keys, with struct literals remaining for compatibility.
+func NewHOTPKey(secret string, hf HashFunction, digits byte,
+	counter uint64) (*HOTPKey, error) {
+	k := &HOTPKey{
+		SecretKey:    secret,
+		HashFunction: hf,
+		Digits:       digits,
+		Counter:      counter,
+	}
+	if err := k.ValidateDetailed(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Returns a TOTPKey for the base-32 encoded secret-key, or the error of
+// ValidateDetailed if the parameter-set is invalid. It is the TOTP counterpart
+// of NewHOTPKey; NewTOTPKey instead generates a secret-key.
+func NewTOTPKeyFrom(secret string, hf HashFunction, digits byte,
+	timeStep, t0 uint64) (*TOTPKey, error) {
+	k := &TOTPKey{
+		SecretKey:    secret,
+		HashFunction: hf,
+		Digits:       digits,
+		TimeStep:     timeStep,
+		T0:           t0,
+	}
+	if err := k.ValidateDetailed(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Returns an HOTPKey for the raw secret-key, which is base-32 encoded using
+// EncodingStd.
+func HOTPKeyFromBytes(secret []byte, hf HashFunction, digits byte,
+	counter uint64) *HOTPKey {
+	return &HOTPKey{
+		SecretKey:    base32.StdEncoding.EncodeToString(secret),
+		HashFunction: hf,
+		Digits:       digits,
+		Counter:      counter,
+	}
+}
+
+// Returns a TOTPKey for the raw secret-key, which is base-32 encoded using
+// EncodingStd.
+func TOTPKeyFromBytes(secret []byte, hf HashFunction, digits byte,
+	timeStep, t0 uint64) *TOTPKey {
+	return &TOTPKey{
+		SecretKey:    base32.StdEncoding.EncodeToString(secret),
+		HashFunction: hf,
+		Digits:       digits,
+		TimeStep:     timeStep,
+		T0:           t0,
+	}
+}
+
+// Decodes a secret-key encoded as specified by e. Base-32 secrets whose length
+// is not a multiple of 8 are taken to have had their padding stripped, as is
+// common in QR codes. Whitespace and hyphens are ignored in base-32 and
+// base-16 secrets alike.
+func decodeSecret(s string, e SecretEncoding) ([]byte, error) {
+	var enc *base32.Encoding
+	switch e {
+	case EncodingStd:
+		enc = base32.StdEncoding
+	case EncodingHex:
+		enc = base32.HexEncoding
+	case EncodingRaw:
+		return []byte(s), nil
+	case EncodingBase16:
+		b, err := hex.DecodeString(normalizeSecret(s))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadBase16, err)
+		}
+		return b, nil
+	default:
+		return nil, ErrUnknownEncoding
+	}
+	return decodeBase32(enc, normalizeSecret(s))
+}
+
+// Returned for base-32 secret-keys whose unpadded length leaves part of a
+// final byte, as a truncated copy does: base-32 encodes 5 bytes in 8
+// characters, so 1, 3, or 6 characters past a whole block cannot occur.
+var ErrMalformedSecret = fmt.Errorf("%w: length leaves a partial byte", ErrBadBase32)
+
+// Returned for base-32 secret-keys whose final character has non-zero unused
+// bits, which decode as if they were zero. As these never result from
+// encoding, they suggest a secret-key that was mistyped or corrupted.
+var ErrNonCanonicalSecret = fmt.Errorf("%w: non-zero trailing bits", ErrBadBase32)
+
+// Decodes s with enc, allowing the padding to be omitted.
+func decodeBase32(enc *base32.Encoding, s string) ([]byte, error) {
+	if n := len(s) % 8; n != 0 {
+		if n == 1 || n == 3 || n == 6 {
+			return nil, ErrMalformedSecret
+		}
+		enc = enc.WithPadding(base32.NoPadding)
+	}
+	sk, err := enc.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadBase32, err)
+	}
+	// The decoder ignores the unused bits of the final character, so that
+	// several strings decode alike; only the canonical one re-encodes to s.
+	if enc.EncodeToString(sk) != s {
+		return nil, ErrNonCanonicalSecret
+	}
+	return sk, nil
+}
+
+// Sets a custom base-32 encoding, such as one returned by base32.NewEncoding,
+// with which SecretKey is decoded in place of SecretEncoding, for deployments
+// using a non-standard alphabet. As with EncodingStd, the padding may be
+// omitted, but SecretKey is otherwise decoded as is. The alphabet must be the
+// one SecretKey was encoded with: most secret-keys decode under several
+// alphabets, so a mismatch yields wrong OTPs rather than an error. Passing nil
+// restores SecretEncoding. The encoding is not marshalled, so it must be set
+// on a key before unmarshalling into it, which keeps it.
+func (k *HOTPKey) SetBase32Encoding(enc *base32.Encoding) {
+	k.alphabet = enc
+}
+
+// Sets a custom base-32 encoding with which SecretKey is decoded. See
+// HOTPKey.SetBase32Encoding.
+func (k *TOTPKey) SetBase32Encoding(enc *base32.Encoding) {
+	k.alphabet = enc
+}
+
+// Sets whether secret-keys shorter than MinKeySize are allowed, in which case
+// any non-empty secret-key validates. This is INSECURE: short secret-keys can
+// be brute-forced from a few OTPs. It is only for tests using short seeds and
+// for interoperating with legacy tokens. The setting is not marshalled, so it
+// must be set on a key before unmarshalling into it, which keeps it.
+func (k *HOTPKey) SetAllowShortSecret(allow bool) {
+	k.allowShort = allow
+}
+
+// Sets whether secret-keys shorter than MinKeySize are allowed. This is
+// INSECURE; see HOTPKey.SetAllowShortSecret.
+func (k *TOTPKey) SetAllowShortSecret(allow bool) {
+	k.allowShort = allow
+}
+
+// Copies the settings of the Set methods, which are not marshalled, from
+// other, so that unmarshalling into a key keeps them.
+func (k *HOTPKey) copySettings(other *HOTPKey) {
+	k.alphabet, k.hashFn, k.allowShort = other.alphabet, other.hashFn, other.allowShort
+}
+
+// Copies the settings of the Set methods from other. See
+// HOTPKey.copySettings.
+func (k *TOTPKey) copySettings(other *TOTPKey) {
+	k.alphabet, k.hashFn, k.allowShort = other.alphabet, other.hashFn, other.allowShort
+}
+
+// Decodes the secret-key, with the custom base-32 encoding, if one was set,
+// and as specified by SecretEncoding otherwise.
+func (k *HOTPKey) secret() ([]byte, error) {
+	if k.alphabet == nil {
+		return decodeSecret(k.SecretKey, k.SecretEncoding)
+	}
+	return decodeBase32(k.alphabet, k.SecretKey)
+}
+
+// Normalizes the human-friendly forms in which secret-keys are displayed, such
+// as "jbsw y3dp ehpk 3pxp", by uppercasing and stripping whitespace and
+// hyphens. Any other invalid character is left for the decoder to reject.
+func normalizeSecret(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
@@ -0,0 +1,509 @@
+package otp
+
+import (
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	for _, n := range []int{MinKeySize, 20, 32, 64} {
+		sk, err := GenerateSecret(n)
+		if err != nil {
+			t.Errorf("Failure: size %d: %v", n, err)
+			continue
+		}
+		b, err := base32.StdEncoding.DecodeString(sk)
+		if err != nil || len(b) != n {
+			t.Errorf("Failure: size %d: secret %q decodes to %d bytes (%v)", n, sk, len(b), err)
+		}
+	}
+	for _, n := range []int{-1, 0, MinKeySize - 1} {
+		if _, err := GenerateSecret(n); err == nil {
+			t.Errorf("Failure: size %d accepted", n)
+		}
+	}
+
+	a, _ := GenerateSecret(20)
+	b, _ := GenerateSecret(20)
+	if a == b {
+		t.Errorf("Failure: two generated secrets are identical: %s", a)
+	}
+}
+
+func TestRecommendedSecretLen(t *testing.T) {
+	w := []struct {
+		hf     HashFunction
+		expect int
+	}{
+		{"", 20},
+		{SHA1, 20},
+		{SHA256, 32},
+		{SHA512, 64},
+		{"MD4", 0},
+	}
+	for _, v := range w {
+		k := HOTPKey{HashFunction: v.hf}
+		if n := k.RecommendedSecretLen(); n != v.expect {
+			t.Errorf("Mismatch on %q\nWant: %d Got: %d", v.hf, v.expect, n)
+		}
+		tk := TOTPKey{HashFunction: v.hf}
+		if n := tk.RecommendedSecretLen(); n != v.expect {
+			t.Errorf("Mismatch on %q\nWant: %d Got: %d", v.hf, v.expect, n)
+		}
+		sk, err := GenerateSecretFor(v.hf)
+		if v.expect == 0 {
+			if err != ErrUnknownHash {
+				t.Errorf("Failure: %q: got error %v, want %v", v.hf, err, ErrUnknownHash)
+			}
+			continue
+		}
+		if b, _ := base32.StdEncoding.DecodeString(sk); err != nil || len(b) != v.expect {
+			t.Errorf("Failure: %q: secret %q decodes to %d bytes (%v)", v.hf, sk, len(b), err)
+		}
+	}
+}
+
+func TestSecretSizeWarning(t *testing.T) {
+	w := []struct {
+		size    int
+		hf      HashFunction
+		warning error
+	}{
+		{16, SHA1, ErrSecretBelowRecommended},
+		{20, SHA1, nil},
+		{20, SHA256, ErrSecretBelowRecommended},
+		{32, SHA256, nil},
+		{16, SHA512, ErrSecretBelowRecommended},
+		{32, SHA512, ErrSecretBelowRecommended},
+		{64, SHA512, nil},
+		{100, SHA512, nil},
+	}
+	for _, v := range w {
+		secret := make([]byte, v.size)
+		k := HOTPKeyFromBytes(secret, v.hf, 6, 0)
+		if err := k.SecretSizeWarning(); err != v.warning {
+			t.Errorf("Failure: %d-byte %s key: got warning %v, want %v", v.size, v.hf, err, v.warning)
+		}
+		tk := TOTPKeyFromBytes(secret, v.hf, 6, 30, 0)
+		if err := tk.SecretSizeWarning(); err != v.warning {
+			t.Errorf("Failure: %d-byte %s key: got warning %v, want %v", v.size, v.hf, err, v.warning)
+		}
+		// The warning is not an error.
+		if !k.Validate() || !tk.Validate() {
+			t.Errorf("Failure: %d-byte %s key is invalid", v.size, v.hf)
+		}
+	}
+	if err := HOTPKeyFromBytes(make([]byte, 8), SHA512, 6, 0).SecretSizeWarning(); err != nil {
+		t.Errorf("Failure: invalid key: got warning %v", err)
+	}
+	if n := MinKeySizeFor(SHA512); n != 64 {
+		t.Errorf("Mismatch on MinKeySizeFor(SHA512)\nWant: 64 Got: %d", n)
+	}
+}
+
+func TestSecretStrengthOK(t *testing.T) {
+	w := []struct {
+		secret []byte
+		expect bool
+	}{
+		{[]byte("12345678901234567890"), true},
+		{make([]byte, 20), false},
+		{[]byte("AAAAAAAAAAAAAAAAAAAA"), false},
+		{[]byte("abababababababababab"), false},
+		{[]byte("abcdefgabcdefgabcdefg"), false},
+		{[]byte("abcdefghabcdefghabcd"), true},
+		{nil, false},
+	}
+	for _, v := range w {
+		k := HOTPKeyFromBytes(v.secret, SHA1, 6, 0)
+		if ok := k.SecretStrengthOK(); ok != v.expect {
+			t.Errorf("Mismatch on secret %q\nWant: %t Got: %t", v.secret, v.expect, ok)
+		}
+		tk := TOTPKeyFromBytes(v.secret, SHA1, 6, 30, 0)
+		if ok := tk.SecretStrengthOK(); ok != v.expect {
+			t.Errorf("Mismatch on secret %q\nWant: %t Got: %t", v.secret, v.expect, ok)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		k, _ := NewTOTPKey()
+		if !k.SecretStrengthOK() {
+			t.Errorf("Failure: generated secret %q deemed weak", k.SecretKey)
+		}
+	}
+	if (&HOTPKey{SecretKey: "NOTBASE32!"}).SecretStrengthOK() {
+		t.Errorf("Failure: undecodable secret deemed strong")
+	}
+	// The check is opt-in: weak keys remain valid.
+	if !HOTPKeyFromBytes(make([]byte, 20), SHA1, 6, 0).Validate() {
+		t.Errorf("Failure: all-zero secret no longer validates")
+	}
+}
+
+func TestNewHOTPKey(t *testing.T) {
+	k, err := NewHOTPKey("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SHA1, 8, 1)
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	// The RFC 4226 appendix D value for counter 1, with 8 digits.
+	if otp := k.OTP(); otp != "94287082" {
+		t.Errorf("Mismatch on key %v\nWant: 94287082 Got: %s", k, otp)
+	}
+	w := []struct {
+		secret string
+		hf     HashFunction
+		digits byte
+		expect error
+	}{
+		{"NOTBASE32!", SHA1, 6, ErrBadBase32},
+		{"GEZDGNBVGY3TQOJQ", SHA1, 6, ErrSecretTooShort},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "MD4", 6, ErrUnknownHash},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SHA1, 0, ErrZeroDigits},
+	}
+	for _, v := range w {
+		if k, err := NewHOTPKey(v.secret, v.hf, v.digits, 0); !errors.Is(err, v.expect) || k != nil {
+			t.Errorf("Failure on %+v: got (%v, %v), want %v", v, k, err, v.expect)
+		}
+		if k, err := NewTOTPKeyFrom(v.secret, v.hf, v.digits, 30, 0); !errors.Is(err, v.expect) || k != nil {
+			t.Errorf("Failure on %+v: got (%v, %v), want %v", v, k, err, v.expect)
+		}
+	}
+}
+
+func TestNewTOTPKeyFrom(t *testing.T) {
+	k, err := NewTOTPKeyFrom("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SHA1, 8, 30, 0)
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	// The RFC 6238 appendix B value for time 59.
+	if otp := k.OTPAt(time.Unix(59, 0)); otp != "94287082" {
+		t.Errorf("Mismatch on key %v\nWant: 94287082 Got: %s", k, otp)
+	}
+	if _, err := NewTOTPKeyFrom("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SHA1, 6, 0, 0); err != ErrInvalidTimeStep {
+		t.Errorf("Failure: zero time-step: got %v, want %v", err, ErrInvalidTimeStep)
+	}
+	if _, err := NewTOTPKeyFrom("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SHA1, 6, 30, 1<<40); err != ErrT0InFuture {
+		t.Errorf("Failure: T0 in the future: got %v, want %v", err, ErrT0InFuture)
+	}
+}
+
+func TestNewTOTPKey(t *testing.T) {
+	k, err := NewTOTPKey()
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if !k.Validate() {
+		t.Errorf("Failure: new key is invalid: %+v", k)
+	}
+	if k.HashFunction != SHA1 || k.Digits != 6 || k.TimeStep != 30 || k.T0 != 0 {
+		t.Errorf("Failure: new key has unexpected parameters: %+v", k)
+	}
+}
+
+func TestNewTOTPKeyWithStep(t *testing.T) {
+	w := []struct {
+		step   time.Duration
+		expect uint64
+		err    error
+	}{
+		{30 * time.Second, 30, nil},
+		{time.Second, 1, nil},
+		{time.Hour, 3600, nil},
+		{0, 0, ErrInvalidTimeStep},
+		{-time.Second, 0, ErrInvalidTimeStep},
+		{500 * time.Millisecond, 0, ErrStepNotSeconds},
+		{1500 * time.Millisecond, 0, ErrStepNotSeconds},
+	}
+	for _, v := range w {
+		k, err := NewTOTPKeyWithStep(v.step)
+		if err != v.err {
+			t.Errorf("Failure: step %v: got error %v, want %v", v.step, err, v.err)
+			continue
+		}
+		if err == nil && (k.TimeStep != v.expect || !k.Validate()) {
+			t.Errorf("Mismatch on step %v\nWant: %d Got: %d", v.step, v.expect, k.TimeStep)
+		}
+	}
+}
+
+func TestNewEnterpriseTOTPKey(t *testing.T) {
+	k, err := NewEnterpriseTOTPKey()
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if k.HashFunction != SHA256 || k.Digits != 8 || k.TimeStep != 60 || k.T0 != 0 {
+		t.Errorf("Failure: unexpected parameter-set %+v", k)
+	}
+	if err := k.ValidateDetailed(); err != nil {
+		t.Errorf("Failure: invalid key: %v", err)
+	}
+	if b, _ := k.hotp(0).secret(); len(b) != k.RecommendedSecretLen() {
+		t.Errorf("Mismatch on secret length\nWant: %d Got: %d", k.RecommendedSecretLen(), len(b))
+	}
+	if otp := k.OTP(); len(otp) != 8 {
+		t.Errorf("Failure: OTP %q is not 8 digits", otp)
+	}
+}
+
+func TestUnpaddedSecret(t *testing.T) {
+	w := []struct {
+		padded, unpadded string
+	}{
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY======", "GEZDGNBVGY3TQOJQGEZDGNBVGY"},
+		{"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXPJA======", "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXPJA"},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA"},
+	}
+	for _, v := range w {
+		p := HOTPKey{SecretKey: v.padded, HashFunction: SHA1, Digits: 6, Counter: 1}
+		u := HOTPKey{SecretKey: v.unpadded, HashFunction: SHA1, Digits: 6, Counter: 1}
+		if !u.Validate() {
+			t.Errorf("Failure: unpadded key marked as invalid: %+v", u)
+			continue
+		}
+		if p.OTP() != u.OTP() {
+			t.Errorf("Mismatch between %+v and %+v:\n%s != %s", p, u, p.OTP(), u.OTP())
+		}
+	}
+
+	// A wrongly padded secret is still rejected.
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY===", HashFunction: SHA1, Digits: 6, Counter: 1}
+	if k.Validate() {
+		t.Errorf("Failure: invalid key marked as valid: %+v", k)
+	}
+}
+
+func TestMalformedSecret(t *testing.T) {
+	// Secret-keys of 21, 22, and 24 bytes (34, 36, and 39 characters,
+	// unpadded), and those same secret-keys one character short.
+	for _, n := range []int{21, 22, 24} {
+		sk := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("123456789012345678901234")[:n])
+		k := HOTPKey{SecretKey: sk, HashFunction: SHA1, Digits: 6}
+		if err := k.ValidateDetailed(); err != nil {
+			t.Errorf("Failure: secret %q: %v", k.SecretKey, err)
+		}
+		k.SecretKey = sk[:len(sk)-1]
+		if err := k.ValidateDetailed(); err != ErrMalformedSecret || !errors.Is(err, ErrBadBase32) {
+			t.Errorf("Failure: secret %q: got %v, want %v", k.SecretKey, err, ErrMalformedSecret)
+		}
+		k.SetBase32Encoding(base32.StdEncoding)
+		if err := k.ValidateDetailed(); err != ErrMalformedSecret {
+			t.Errorf("Failure: secret %q with a custom encoding: got %v, want %v", k.SecretKey, err, ErrMalformedSecret)
+		}
+	}
+	// The length is that of the normalized secret-key.
+	k := HOTPKey{SecretKey: "gezd gnbv gy3t qojq gezd gnbv gy3t qojq g", HashFunction: SHA1, Digits: 6}
+	if err := k.ValidateDetailed(); err != ErrMalformedSecret {
+		t.Errorf("Failure: secret %q: got %v, want %v", k.SecretKey, err, ErrMalformedSecret)
+	}
+}
+
+func TestAllowShortSecret(t *testing.T) {
+	// A 10-byte seed: the first half of the RFC 4226 one.
+	k := HOTPKey{SecretKey: "1234567890", SecretEncoding: EncodingRaw, HashFunction: SHA1, Digits: 6}
+	if err := k.ValidateDetailed(); err != ErrSecretTooShort {
+		t.Fatalf("Failure: short seed: got %v, want %v", err, ErrSecretTooShort)
+	}
+	k.SetAllowShortSecret(true)
+	if err := k.ValidateDetailed(); err != nil {
+		t.Fatalf("Failure: short seed with the override: %v", err)
+	}
+	if otp, err := k.Generate(); err != nil || len(otp) != 6 {
+		t.Errorf("Failure: short seed with the override: got (%q, %v)", otp, err)
+	}
+
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	if tk.Validate() {
+		t.Errorf("Failure: short seed marked as valid")
+	}
+	tk.SetAllowShortSecret(true)
+	if err := tk.ValidateDetailed(); err != nil {
+		t.Errorf("Failure: short seed with the override: %v", err)
+	}
+
+	// The secret-key must still be non-empty.
+	k.SecretKey = ""
+	if err := k.ValidateDetailed(); err != ErrSecretTooShort {
+		t.Errorf("Failure: empty seed with the override: got %v, want %v", err, ErrSecretTooShort)
+	}
+	k.SetAllowShortSecret(false)
+	k.SecretKey = "1234567890"
+	if k.Validate() {
+		t.Errorf("Failure: short seed valid after the override was cleared")
+	}
+}
+
+func TestNonCanonicalSecret(t *testing.T) {
+	// "GEZDGNBVGY3TQOJQGEZDGNBVGY" encodes 16 bytes, the last character
+	// carrying 1 unused bit; "GEZDGNBVGY3TQOJQGEZDGNBVGZ" sets it.
+	w := []struct {
+		secret string
+		expect error
+	}{
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY", nil},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGZ", ErrNonCanonicalSecret},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY======", nil},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGZ======", ErrNonCanonicalSecret},
+		{"gezd gnbv gy3t qojq gezd gnbv gz", ErrNonCanonicalSecret},
+		// 20 bytes fill whole characters, so any final character is canonical.
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJR", nil},
+	}
+	for _, v := range w {
+		k := HOTPKey{SecretKey: v.secret, HashFunction: SHA1, Digits: 6}
+		if err := k.ValidateDetailed(); err != v.expect {
+			t.Errorf("Failure: secret %q: got %v, want %v", v.secret, err, v.expect)
+		}
+		if v.expect != nil && !errors.Is(k.ValidateDetailed(), ErrBadBase32) {
+			t.Errorf("Failure: secret %q: error does not wrap %v", v.secret, ErrBadBase32)
+		}
+	}
+
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGZ", HashFunction: SHA1, Digits: 6}
+	k.SetBase32Encoding(base32.StdEncoding)
+	if err := k.ValidateDetailed(); err != ErrNonCanonicalSecret {
+		t.Errorf("Failure: custom encoding: got %v, want %v", err, ErrNonCanonicalSecret)
+	}
+}
+
+func TestFormattedSecret(t *testing.T) {
+	want := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 1}
+	formatted := []string{
+		"gezdgnbvgy3tqojqgezdgnbvgy3tqojq",
+		"gezd gnbv gy3t qojq gezd gnbv gy3t qojq",
+		"GEZD-GNBV-GY3T-QOJQ-GEZD-GNBV-GY3T-QOJQ",
+		" gEzD\tGNBV\nGY3T-qojq GEZDGNBVGY3TQOJQ ",
+	}
+	for _, v := range formatted {
+		k := HOTPKey{SecretKey: v, HashFunction: SHA1, Digits: 6, Counter: 1}
+		if !k.Validate() {
+			t.Errorf("Failure: formatted key marked as invalid: %+v", k)
+		} else if k.OTP() != want.OTP() {
+			t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", k, want.OTP(), k.OTP())
+		}
+	}
+
+	invalid := []string{
+		"GEZD_GNBV_GY3T_QOJQ_GEZD_GNBV_GY3T_QOJQ",
+		"GEZD.GNBV.GY3T.QOJQ.GEZD.GNBV.GY3T.QOJQ",
+		"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJ1",
+	}
+	for _, v := range invalid {
+		k := HOTPKey{SecretKey: v, HashFunction: SHA1, Digits: 6, Counter: 1}
+		if k.Validate() {
+			t.Errorf("Failure: invalid key marked as valid: %+v", k)
+		}
+	}
+}
+
+func TestSecretEncoding(t *testing.T) {
+	raw := []byte("12345678901234567890")
+	std := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, Counter: 1}
+	w := []*HOTPKey{
+		{SecretKey: "64P36D1L6ORJGE9G64P36D1L6ORJGE9G", SecretEncoding: EncodingHex, HashFunction: SHA1, Digits: 8, Counter: 1},
+		{SecretKey: "64p3 6d1l 6orj ge9g 64p3 6d1l 6orj ge9g", SecretEncoding: EncodingHex, HashFunction: SHA1, Digits: 8, Counter: 1},
+		{SecretKey: string(raw), SecretEncoding: EncodingRaw, HashFunction: SHA1, Digits: 8, Counter: 1},
+		{SecretKey: "3132333435363738393031323334353637383930", SecretEncoding: EncodingBase16, HashFunction: SHA1, Digits: 8, Counter: 1},
+		{SecretKey: "31 32 33 34 35 36 37 38 39 30 31 32 33 34 35 36 37 38 39 30", SecretEncoding: EncodingBase16, HashFunction: SHA1, Digits: 8, Counter: 1},
+		HOTPKeyFromBytes(raw, SHA1, 8, 1),
+	}
+	for _, k := range w {
+		if !k.Validate() {
+			t.Errorf("Failure: valid key marked as invalid: %+v", k)
+		} else if k.OTP() != std.OTP() {
+			t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", k, std.OTP(), k.OTP())
+		}
+	}
+
+	tk := TOTPKeyFromBytes(raw, SHA1, 8, 30, 0)
+	tk.SecretKey, tk.SecretEncoding = string(raw), EncodingRaw
+	if otp := tk.OTPAt(time.Unix(59, 0)); otp != "94287082" {
+		t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", tk, "94287082", otp)
+	}
+
+	// Base-32 hex is not hexadecimal: a hexadecimal secret-key decodes
+	// differently under EncodingHex, if at all.
+	hk := HOTPKey{SecretKey: "3132333435363738393031323334353637383930", SecretEncoding: EncodingHex, HashFunction: SHA1, Digits: 8, Counter: 1}
+	if hk.Validate() && hk.OTP() == std.OTP() {
+		t.Errorf("Failure: EncodingHex decoded hexadecimal")
+	}
+	if err := (&HOTPKey{SecretKey: "zz", SecretEncoding: EncodingBase16, HashFunction: SHA1, Digits: 8}).ValidateDetailed(); !errors.Is(err, ErrBadBase16) {
+		t.Errorf("Failure: got error %v, want %v", err, ErrBadBase16)
+	}
+
+	invalid := []HOTPKey{
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SecretEncoding: EncodingHex, HashFunction: SHA1, Digits: 8},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SecretEncoding: 4, HashFunction: SHA1, Digits: 8},
+		{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", SecretEncoding: EncodingBase16, HashFunction: SHA1, Digits: 8},
+		{SecretKey: "313233343536373839303132333435363738393", SecretEncoding: EncodingBase16, HashFunction: SHA1, Digits: 8},
+		{SecretKey: "short", SecretEncoding: EncodingRaw, HashFunction: SHA1, Digits: 8},
+	}
+	for _, k := range invalid {
+		if k.Validate() {
+			t.Errorf("Failure: invalid key marked as valid: %+v", k)
+		}
+	}
+}
+
+func TestBase32Encoding(t *testing.T) {
+	// The standard alphabet reversed, so that secret-keys decode under either.
+	reversed := base32.NewEncoding("765432ZYXWVUTSRQPONMLKJIHGFEDCBA")
+	sk := reversed.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	hk := HOTPKey{SecretKey: sk, HashFunction: SHA1, Digits: 6}
+	hk.SetBase32Encoding(reversed)
+	// The RFC 4226 appendix D value for counter 0.
+	if otp, err := hk.Generate(); err != nil || otp != "755224" {
+		t.Errorf("Mismatch on key %+v:\nWant: 755224 Got: %s (%v)", hk, otp, err)
+	}
+	tk := TOTPKey{SecretKey: sk, HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	tk.SetBase32Encoding(reversed)
+	// The RFC 6238 appendix B value for time 59.
+	if otp, err := tk.GenerateAt(time.Unix(59, 0)); err != nil || otp != "94287082" {
+		t.Errorf("Mismatch on key %+v:\nWant: 94287082 Got: %s (%v)", tk, otp, err)
+	}
+	want := "otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=SHA1&digits=8&period=30"
+	if uri := tk.URI("", "alice"); uri != want {
+		t.Errorf("Mismatch on URI:\nWant: %s Got: %s", want, uri)
+	}
+
+	// A mismatched alphabet decodes, but to the wrong secret-key.
+	hk.SetBase32Encoding(nil)
+	if otp, err := hk.Generate(); err != nil || otp == "755224" {
+		t.Errorf("Failure: standard alphabet on a reversed-alphabet secret: got (%s, %v)", otp, err)
+	}
+	hk.SetBase32Encoding(reversed)
+	hk.SecretKey = "0123456789ABCDEF"
+	if err := hk.ValidateDetailed(); !errors.Is(err, ErrBadBase32) {
+		t.Errorf("Failure: secret outside alphabet: got %v, want %v", err, ErrBadBase32)
+	}
+}
+
+func FuzzDecodeSecret(f *testing.F) {
+	for _, s := range []string{
+		"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"gezd gnbv gy3t qojq",
+		"GEZDGNBVGY3TQOJQGEZDGNBV========",
+		"GEZDGNBVGY3TQOJ!",
+		"",
+	} {
+		f.Add(s, byte(EncodingStd))
+		f.Add(s, byte(EncodingHex))
+		f.Add(s, byte(EncodingBase16))
+	}
+	f.Fuzz(func(t *testing.T, s string, e byte) {
+		sk, err := decodeSecret(s, SecretEncoding(e))
+		if err != nil {
+			return
+		}
+		// Whatever decodes must yield a key that validates, or fails to for
+		// a reason other than its secret-key, without panicking.
+		k := HOTPKey{SecretKey: s, SecretEncoding: SecretEncoding(e), HashFunction: SHA1, Digits: 6}
+		err = k.ValidateDetailed()
+		if err != nil && (len(sk) >= MinKeySize || err != ErrSecretTooShort) {
+			t.Fatalf("Failure: %q decodes to %d bytes, but the key is invalid: %v", s, len(sk), err)
+		}
+		if err == nil {
+			k.OTP()
+		}
+	})
+}
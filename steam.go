@@ -0,0 +1,45 @@
+package otp
+
+import (
+	"time"
+)
+
+// The alphabet of Steam Guard codes, which omits easily confused characters.
+const steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+
+// The length of Steam Guard codes.
+const steamDigits = 5
+
+// Computes and returns a Steam Guard code using the TOTP parameter-set. Steam
+// Guard keys use SHA1 and a time-step of 30 seconds, but the truncated value is
+// encoded as 5 characters of Steam's own alphabet rather than as decimal
+// digits; Digits is ignored. If the receiver TOTPKey is invalid, OnInvalidKey
+// is called.
+func (k *TOTPKey) SteamCode() string {
+	return k.SteamCodeAt(time.Now())
+}
+
+// Computes and returns the Steam Guard code for time t using the TOTP
+// parameter-set. If the receiver TOTPKey is invalid, OnInvalidKey is called.
+func (k *TOTPKey) SteamCodeAt(t time.Time) string {
+	if err := k.ValidateDetailed(); err != nil {
+		invalidKey(err)
+		return ""
+	}
+	h, err := k.conv(t)
+	if err != nil {
+		invalidKey(err)
+		return ""
+	}
+	b, err := h.Value()
+	if err != nil {
+		invalidKey(err)
+		return ""
+	}
+	var res [steamDigits]byte
+	for i := range res {
+		res[i] = steamAlphabet[b%uint32(len(steamAlphabet))]
+		b /= uint32(len(steamAlphabet))
+	}
+	return string(res[:])
+}
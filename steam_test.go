@@ -0,0 +1,25 @@
+package otp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSteamCode(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 5, TimeStep: 30}
+	// Computed with an independent implementation of the Steam Guard encoding.
+	w := []struct {
+		at     int64
+		expect string
+	}{
+		{59, "PV9M4"},
+		{1111111109, "PY4YB"},
+		{1234567890, "VHHQY"},
+		{2000000000, "9N776"},
+	}
+	for _, v := range w {
+		if code := k.SteamCodeAt(time.Unix(v.at, 0)); code != v.expect {
+			t.Errorf("Mismatch at time %d:\nWant: %s Got: %s", v.at, v.expect, code)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package otp
+
+import (
+	"sync"
+)
+
+// Persists the keys of a Store. Load may be called concurrently with other
+// calls to Load, but the Store serializes all other access.
+type KeyBackend interface {
+	Load(id string) (OTPGenerator, bool)
+	Save(id string, key OTPGenerator)
+	Delete(id string)
+}
+
+// A KeyBackend that keeps keys in memory.
+type memoryBackend map[string]OTPGenerator
+
+func (b memoryBackend) Load(id string) (OTPGenerator, bool) {
+	k, ok := b[id]
+	return k, ok
+}
+
+func (b memoryBackend) Save(id string, key OTPGenerator) {
+	b[id] = key
+}
+
+func (b memoryBackend) Delete(id string) {
+	delete(b, id)
+}
+
+// A thread-safe store of keys by ID, which verifies codes against them. The
+// zero value is an empty store kept in memory.
+type Store struct {
+	// The number of time-steps tolerated on either side when verifying TOTP
+	// codes.
+	Skew uint
+	// The number of subsequent counters tried when verifying HOTP codes.
+	LookAhead uint
+
+	mu      sync.RWMutex
+	backend KeyBackend
+}
+
+// Returns a Store persisting its keys to b.
+func NewStore(b KeyBackend) *Store {
+	return &Store{backend: b}
+}
+
+// Must be called with mu held.
+func (s *Store) keys() KeyBackend {
+	if s.backend == nil {
+		s.backend = make(memoryBackend)
+	}
+	return s.backend
+}
+
+// Adds key under id, replacing any key already there. *HOTPKey and *TOTPKey
+// keys are copied, so that later changes to key do not affect the store.
+func (s *Store) Add(id string, key OTPGenerator) {
+	switch k := key.(type) {
+	case *HOTPKey:
+		key = k.Clone()
+	case *TOTPKey:
+		key = k.Clone()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys().Save(id, key)
+}
+
+// Returns the key under id, if there is one.
+func (s *Store) Get(id string) (OTPGenerator, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.backend == nil {
+		return nil, false
+	}
+	return s.backend.Load(id)
+}
+
+// Deletes the key under id, if there is one.
+func (s *Store) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys().Delete(id)
+}
+
+// Verifies code against the key under id. For HOTP keys, the resynchronized
+// counter is saved after a successful verification, so that the code cannot be
+// used again.
+func (s *Store) Verify(id, code string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys().Load(id)
+	if !ok {
+		return false
+	}
+	switch k := key.(type) {
+	case *HOTPKey:
+		ok, ctr := k.Verify(code, s.LookAhead)
+		if ok {
+			c := k.Clone()
+			c.Counter = ctr
+			s.keys().Save(id, c)
+		}
+		return ok
+	case *TOTPKey:
+		return k.Verify(code, s.Skew)
+	default:
+		otp, err := k.Generate()
+		return err == nil && Equal(otp, trimCode(code))
+	}
+}
@@ -0,0 +1,95 @@
+package otp
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	var s Store
+	hk := &HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}
+	tk := &TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	s.Add("hotp", hk)
+	s.Add("totp", tk)
+	s.LookAhead, s.Skew = 2, 1
+
+	if _, ok := s.Get("none"); ok {
+		t.Errorf("Failure: missing key found")
+	}
+	if s.Verify("none", "755224") {
+		t.Errorf("Failure: code verified for a missing key")
+	}
+
+	// RFC 4226 appendix D values for counters 0 through 3.
+	if !s.Verify("hotp", "359152") {
+		t.Fatalf("Failure: code for counter 2 rejected")
+	}
+	k, _ := s.Get("hotp")
+	if c := k.(*HOTPKey).Counter; c != 3 {
+		t.Errorf("Failure: stored counter is %d, want 3", c)
+	}
+	if hk.Counter != 0 {
+		t.Errorf("Failure: caller's key mutated to counter %d", hk.Counter)
+	}
+	if s.Verify("hotp", "359152") || s.Verify("hotp", "287082") {
+		t.Errorf("Failure: used code accepted again")
+	}
+	if !s.Verify("hotp", "969429") {
+		t.Errorf("Failure: code for counter 3 rejected")
+	}
+
+	if !s.Verify("totp", tk.OTP()) {
+		t.Errorf("Failure: current TOTP code rejected")
+	}
+
+	s.Delete("totp")
+	if _, ok := s.Get("totp"); ok {
+		t.Errorf("Failure: deleted key found")
+	}
+}
+
+// Records the calls made to it.
+type recordingBackend struct {
+	memoryBackend
+	saves int
+}
+
+func (b *recordingBackend) Save(id string, key OTPGenerator) {
+	b.saves++
+	b.memoryBackend.Save(id, key)
+}
+
+func TestStoreBackend(t *testing.T) {
+	b := &recordingBackend{memoryBackend: make(memoryBackend)}
+	s := NewStore(b)
+	s.Add("hotp", &HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6})
+	s.Verify("hotp", "755224")
+	if b.saves != 2 {
+		t.Errorf("Failure: backend saved %d times, want 2", b.saves)
+	}
+}
+
+// Meant to be run with -race. Each HOTP code must be accepted exactly once.
+func TestStoreConcurrent(t *testing.T) {
+	s := Store{LookAhead: 10}
+	s.Add("hotp", &HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6})
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for g := 0; g < 50; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.Verify("hotp", "969429") {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+			s.Get("hotp")
+		}()
+	}
+	wg.Wait()
+	if accepted != 1 {
+		t.Errorf("Failure: code accepted %d times, want 1", accepted)
+	}
+}
@@ -0,0 +1,249 @@
+package otp
+
+import (
+	"encoding/base32"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Parses an otpauth:// provisioning URI, such as one scanned from a QR code.
+// The returned key is a *TOTPKey for otpauth://totp/ URIs and an *HOTPKey for
+// otpauth://hotp/ URIs. Missing algorithm, digits, and period parameters take
+// the values DefaultHash, DefaultDigits, and DefaultTimeStep. The issuer is
+// taken from the issuer parameter, falling back to the label's prefix.
+func ParseURI(uri string) (key OTPGenerator, issuer, account string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("otp: malformed URI: %w", err)
+	}
+	if !strings.EqualFold(u.Scheme, "otpauth") {
+		return nil, "", "", fmt.Errorf("otp: unsupported URI scheme %q", u.Scheme)
+	}
+
+	q := u.Query()
+	issuer, account, err = parseLabel(strings.TrimPrefix(u.EscapedPath(), "/"),
+		q.Get("issuer"))
+	if err != nil {
+		return nil, "", "", err
+	}
+	if q.Has("issuer") {
+		issuer = q.Get("issuer")
+	}
+
+	secret := q.Get("secret")
+	if secret == "" {
+		return nil, "", "", fmt.Errorf("otp: URI is missing the secret parameter")
+	}
+	hf := DefaultHash
+	if q.Has("algorithm") {
+		hf = canonicalHash(HashFunction(q.Get("algorithm")))
+		if lookupHash(hf) == nil {
+			return nil, "", "", fmt.Errorf("otp: unsupported algorithm %q", q.Get("algorithm"))
+		}
+	}
+	digits := byte(DefaultDigits)
+	if q.Has("digits") {
+		digits, err = ParseDigits(q.Get("digits"))
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	switch strings.ToLower(u.Host) {
+	case "totp":
+		period := uint64(DefaultTimeStep)
+		if q.Has("period") {
+			period, err = ParsePeriod(q.Get("period"))
+			if err != nil {
+				return nil, "", "", err
+			}
+		}
+		k := &TOTPKey{
+			SecretKey:    secret,
+			HashFunction: hf,
+			Digits:       digits,
+			TimeStep:     period,
+		}
+		if !k.Validate() {
+			return nil, "", "", fmt.Errorf("otp: URI describes an invalid TOTPKey")
+		}
+		return k, issuer, account, nil
+	case "hotp":
+		if !q.Has("counter") {
+			return nil, "", "", fmt.Errorf("otp: URI is missing the counter parameter")
+		}
+		counter, err := strconv.ParseUint(q.Get("counter"), 10, 64)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("otp: malformed counter parameter: %w", err)
+		}
+		k := &HOTPKey{
+			SecretKey:    secret,
+			HashFunction: hf,
+			Digits:       digits,
+			Counter:      counter,
+		}
+		if !k.Validate() {
+			return nil, "", "", fmt.Errorf("otp: URI describes an invalid HOTPKey")
+		}
+		return k, issuer, account, nil
+	default:
+		return nil, "", "", fmt.Errorf("otp: unsupported OTP type %q", u.Host)
+	}
+}
+
+// Returns the digits, period, and hash function described by a provisioning
+// URI, leniently, such as for import tools handling partial or malformed URIs
+// exported by various apps. Only the query is read, so the scheme, OTP type,
+// label, and secret may be missing or malformed, and parameter names are
+// case-insensitive. Any parameter that is missing or fails to parse takes the
+// de facto standard value: DefaultDigits, DefaultTimeStep, or DefaultHash. A
+// hash function that is not registered is treated as missing.
+func GuessParams(uri string) (digits byte, period uint64, hash HashFunction) {
+	digits, period, hash = DefaultDigits, DefaultTimeStep, DefaultHash
+	i := strings.Index(uri, "?")
+	if i < 0 {
+		return digits, period, hash
+	}
+	// ParseQuery returns what it could parse alongside its error.
+	q, _ := url.ParseQuery(uri[i+1:])
+	// Sorted, so that of names differing only in case, the last one in sorted
+	// order consistently wins.
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := strings.TrimSpace(q[name][0])
+		switch strings.ToLower(name) {
+		case "digits":
+			if d, err := ParseDigits(v); err == nil {
+				digits = d
+			}
+		case "period":
+			if p, err := ParsePeriod(v); err == nil {
+				period = p
+			}
+		case "algorithm":
+			if hf := canonicalHash(HashFunction(v)); v != "" && lookupHash(hf) != nil {
+				hash = hf
+			}
+		}
+	}
+	return digits, period, hash
+}
+
+// Splits an escaped "issuer:account" label. The separator may be a literal or
+// an encoded colon; a literal one is preferred so that encoded colons within
+// the issuer survive. An encoded colon is not taken as the separator if what
+// precedes it differs from the non-empty issuer parameter, since the label is
+// then an account, without the issuer, that contains a colon.
+func parseLabel(label, issuerParam string) (issuer, account string, err error) {
+	i := strings.Index(label, ":")
+	if i < 0 {
+		label, err = url.PathUnescape(label)
+		if err != nil {
+			return "", "", fmt.Errorf("otp: malformed URI label: %w", err)
+		}
+		i = strings.Index(label, ":")
+		if i < 0 || (issuerParam != "" && label[:i] != issuerParam) {
+			return "", label, nil
+		}
+		return label[:i], strings.TrimLeft(label[i+1:], " "), nil
+	}
+	issuer, err = url.PathUnescape(label[:i])
+	if err != nil {
+		return "", "", fmt.Errorf("otp: malformed URI label: %w", err)
+	}
+	account, err = url.PathUnescape(label[i+1:])
+	if err != nil {
+		return "", "", fmt.Errorf("otp: malformed URI label: %w", err)
+	}
+	return issuer, strings.TrimLeft(account, " "), nil
+}
+
+// Configures the URIs returned by URI.
+type URIOption func(*uriOptions)
+
+type uriOptions struct {
+	omitIssuerLabel bool
+}
+
+// Labels the URI with only the account, leaving the issuer to the issuer
+// parameter. Some authenticators otherwise display the issuer twice.
+func WithoutIssuerLabel() URIOption {
+	return func(o *uriOptions) {
+		o.omitIssuerLabel = true
+	}
+}
+
+// Returns an otpauth:// provisioning URI for the TOTP parameter-set, labelled
+// "issuer:account". If issuer is empty, the label is only the account and the
+// issuer parameter is omitted. Colons within the issuer or account are
+// percent-encoded. Authenticators know nothing of Domain or ClockOffset, so
+// a key with either cannot be enrolled from its URI: it would never produce
+// matching codes. WriteQRCode rejects such keys.
+func (k *TOTPKey) URI(issuer, account string, opts ...URIOption) string {
+	sk := k.hotp(0).uriSecret()
+	return buildURI("totp", issuer, account, sk, k.HashFunction, k.Digits,
+		"period", k.TimeStep, opts)
+}
+
+// Returns an otpauth:// provisioning URI for the HOTP parameter-set, labelled
+// "issuer:account". If issuer is empty, the label is only the account and the
+// issuer parameter is omitted. Colons within the issuer or account are
+// percent-encoded. Authenticators know nothing of Domain, so a key with one
+// cannot be enrolled from its URI: it would never produce matching codes.
+// WriteQRCode rejects such keys.
+func (k *HOTPKey) URI(issuer, account string, opts ...URIOption) string {
+	sk := k.uriSecret()
+	return buildURI("hotp", issuer, account, sk, k.HashFunction, k.Digits,
+		"counter", k.Counter, opts)
+}
+
+func buildURI(typ, issuer, account, secret string, hf HashFunction,
+	digits byte, param string, value uint64, opts []URIOption) string {
+	var o uriOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var b strings.Builder
+	b.WriteString("otpauth://" + typ + "/")
+	if issuer != "" && !o.omitIssuerLabel {
+		b.WriteString(escape(issuer) + ":")
+	}
+	b.WriteString(escape(account))
+	b.WriteString("?secret=" + escape(secret))
+	if issuer != "" {
+		b.WriteString("&issuer=" + escape(issuer))
+	}
+	if hf == "" {
+		hf = DefaultHash
+	}
+	b.WriteString("&algorithm=" + escape(string(hf)))
+	b.WriteString("&digits=" + strconv.FormatUint(uint64(digits), 10))
+	b.WriteString("&" + param + "=" + strconv.FormatUint(value, 10))
+	return b.String()
+}
+
+// Returns the secret-key in the unpadded, uppercase standard base-32 that
+// URIs require, re-encoding it from whatever form or encoding it is stored in,
+// since authenticators reject the spaced or lowercase forms that SecretKey
+// may take, and the Key URI format omits padding.
+func (k *HOTPKey) uriSecret() string {
+	sk, err := k.secret()
+	if err != nil {
+		return k.SecretKey
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(sk)
+}
+
+// Percent-encodes s for use in either the label or the query of a URI. Unlike
+// url.QueryEscape, spaces become "%20", which authenticators handle
+// consistently in both places.
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
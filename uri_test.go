@@ -0,0 +1,217 @@
+package otp
+
+import (
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	w := []struct {
+		uri     string
+		key     OTPGenerator
+		issuer  string
+		account string
+	}{
+		{
+			"otpauth://totp/ACME%20Co:john@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60",
+			&TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA256, Digits: 8, TimeStep: 60, T0: 0},
+			"ACME Co", "john@example.com",
+		},
+		{
+			"otpauth://totp/Example:alice@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			&TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, T0: 0},
+			"Example", "alice@example.com",
+		},
+		{
+			"otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=sha512",
+			&TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA512, Digits: 6, TimeStep: 30, T0: 0},
+			"", "alice",
+		},
+		{
+			"otpauth://hotp/Example:%20bob?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=42&issuer=Other",
+			&HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 42},
+			"Other", "bob",
+		},
+	}
+	for _, v := range w {
+		key, issuer, account, err := ParseURI(v.uri)
+		if err != nil {
+			t.Errorf("Failure: %s: %v", v.uri, err)
+			continue
+		}
+		switch want := v.key.(type) {
+		case *TOTPKey:
+			if got, ok := key.(*TOTPKey); !ok || *got != *want {
+				t.Errorf("Mismatch on %s:\nWant: %+v Got: %+v", v.uri, want, key)
+			}
+		case *HOTPKey:
+			if got, ok := key.(*HOTPKey); !ok || *got != *want {
+				t.Errorf("Mismatch on %s:\nWant: %+v Got: %+v", v.uri, want, key)
+			}
+		}
+		if issuer != v.issuer || account != v.account {
+			t.Errorf("Mismatch on %s:\nWant: %q, %q Got: %q, %q", v.uri, v.issuer, v.account, issuer, account)
+		}
+	}
+
+	invalid := []string{
+		"https://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"otpauth://motp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"otpauth://totp/alice",
+		"otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=MD5",
+		"otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=six",
+		"otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=11",
+		"otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&period=0",
+		"otpauth://totp/alice?secret=NOTBASE32",
+		"otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=-1",
+	}
+	for _, v := range invalid {
+		if _, _, _, err := ParseURI(v); err == nil {
+			t.Errorf("Failure: invalid URI accepted: %s", v)
+		} else {
+			t.Logf("Success: invalid URI rejected: %s: %v", v, err)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA256, Digits: 8, TimeStep: 60, T0: 0}
+	want := "otpauth://totp/ACME%20Co:john%40example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60"
+	if got := tk.URI("ACME Co", "john@example.com"); got != want {
+		t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", tk, want, got)
+	}
+
+	hk := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 42}
+	want = "otpauth://hotp/bob?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=SHA1&digits=6&counter=42"
+	if got := hk.URI("", "bob"); got != want {
+		t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", hk, want, got)
+	}
+
+	// Other encodings are converted to the standard base-32.
+	rk := HOTPKey{SecretKey: "12345678901234567890", SecretEncoding: EncodingRaw, HashFunction: SHA1, Digits: 6, Counter: 42}
+	if got := rk.URI("", "bob"); got != want {
+		t.Errorf("Mismatch on key %+v:\nWant: %s Got: %s", rk, want, got)
+	}
+
+	// Human-friendly and padded forms are normalized, and padding omitted.
+	w := []struct {
+		secret, expect string
+	}{
+		{"gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"},
+		{"gezd gnbv gy3t qojq gezd gnbv gy3t qojq", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"},
+		{"GEZD-GNBV-GY3T-QOJQ-GEZD-GNBV-GY3T-QOJQ", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA"},
+		{"gezd gnbv gy3t qojq gezd gnbv gy3t qojq geza", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA"},
+	}
+	for _, v := range w {
+		h := HOTPKey{SecretKey: v.secret, HashFunction: SHA1, Digits: 6, Counter: 42}
+		want := "otpauth://hotp/bob?secret=" + v.expect + "&algorithm=SHA1&digits=6&counter=42"
+		if got := h.URI("", "bob"); got != want {
+			t.Errorf("Mismatch on secret %q:\nWant: %s Got: %s", v.secret, want, got)
+		}
+		tk := TOTPKey{SecretKey: v.secret, HashFunction: SHA1, Digits: 6, TimeStep: 30}
+		want = "otpauth://totp/bob?secret=" + v.expect + "&algorithm=SHA1&digits=6&period=30"
+		if got := tk.URI("", "bob"); got != want {
+			t.Errorf("Mismatch on secret %q:\nWant: %s Got: %s", v.secret, want, got)
+		}
+	}
+
+	// Special characters must survive a round trip through ParseURI.
+	issuer, account := "A&B: Ltd?", "a/b:c#d%e"
+	key, gotIssuer, gotAccount, err := ParseURI(tk.URI(issuer, account))
+	if err != nil {
+		t.Fatalf("Failure: %v", err)
+	}
+	if got, ok := key.(*TOTPKey); !ok || *got != tk {
+		t.Errorf("Mismatch on round trip:\nWant: %+v Got: %+v", tk, key)
+	}
+	if gotIssuer != issuer || gotAccount != account {
+		t.Errorf("Mismatch on round trip:\nWant: %q, %q Got: %q, %q", issuer, account, gotIssuer, gotAccount)
+	}
+}
+
+func TestURIWithoutIssuerLabel(t *testing.T) {
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30, T0: 0}
+	w := []struct {
+		issuer, account, expect string
+	}{
+		{"ACME Co", "john@example.com", "otpauth://totp/john%40example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=ACME%20Co&algorithm=SHA1&digits=6&period=30"},
+		{"ACME Co", "a:b", "otpauth://totp/a%3Ab?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=ACME%20Co&algorithm=SHA1&digits=6&period=30"},
+		{"", "bob", "otpauth://totp/bob?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=SHA1&digits=6&period=30"},
+	}
+	for _, v := range w {
+		uri := tk.URI(v.issuer, v.account, WithoutIssuerLabel())
+		if uri != v.expect {
+			t.Errorf("Mismatch on %q, %q:\nWant: %s Got: %s", v.issuer, v.account, v.expect, uri)
+		}
+		_, issuer, account, err := ParseURI(uri)
+		if err != nil {
+			t.Fatalf("Failure: %v", err)
+		}
+		if issuer != v.issuer || account != v.account {
+			t.Errorf("Mismatch on round trip:\nWant: %q, %q Got: %q, %q", v.issuer, v.account, issuer, account)
+		}
+	}
+
+	// A label whose encoded colon separates the issuer is still split.
+	_, issuer, account, err := ParseURI("otpauth://totp/ACME%3Abob?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=ACME")
+	if err != nil || issuer != "ACME" || account != "bob" {
+		t.Errorf("Mismatch on encoded separator:\nWant: %q, %q Got: %q, %q (%v)", "ACME", "bob", issuer, account, err)
+	}
+}
+
+func TestGuessParams(t *testing.T) {
+	w := []struct {
+		uri    string
+		digits byte
+		period uint64
+		hash   HashFunction
+	}{
+		{"otpauth://totp/ACME:bob?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=SHA256&digits=8&period=60", 8, 60, SHA256},
+		// Missing parameters take the defaults.
+		{"otpauth://totp/ACME:bob?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", 6, 30, SHA1},
+		{"otpauth://totp/ACME:bob", 6, 30, SHA1},
+		{"", 6, 30, SHA1},
+		{"?digits=7", 7, 30, SHA1},
+		// The scheme, type, and secret are not checked.
+		{"otpauth:/totp?secret=&digits=8", 8, 30, SHA1},
+		{"otp://x?algorithm=sha512&period=15", 6, 15, SHA512},
+		{"otpauth://totp/bob?Digits=8&PERIOD=45&Algorithm=SHA256", 8, 45, SHA256},
+		// Malformed parameters take the defaults, without affecting the others.
+		{"otpauth://totp/bob?digits=eight&period=60", 6, 60, SHA1},
+		{"otpauth://totp/bob?digits=0&period=0&algorithm=MD5", 6, 30, SHA1},
+		{"otpauth://totp/bob?digits=11&period=-30&algorithm=", 6, 30, SHA1},
+		{"otpauth://totp/bob?digits=%zz&period=60", 6, 60, SHA1},
+		{"otpauth://totp/bob?digits=%208%20", 8, 30, SHA1},
+	}
+	for _, v := range w {
+		d, p, h := GuessParams(v.uri)
+		if d != v.digits || p != v.period || h != v.hash {
+			t.Errorf("Mismatch on %q:\nWant: %d, %d, %s Got: %d, %d, %s", v.uri, v.digits, v.period, v.hash, d, p, h)
+		}
+	}
+}
+
+func FuzzParseURI(f *testing.F) {
+	for _, s := range []string{
+		"otpauth://totp/ACME%20Co:john.doe@email.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA1&digits=6&period=30",
+		"otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=5",
+		"otpauth://totp/a:b:c?secret=gezd-gnbv-gy3t-qojq&digits=8&algorithm=sha512",
+		"otpauth://totp/?secret=&period=0",
+		"otpauth://hotp/%zz?counter=-1",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, uri string) {
+		key, _, _, err := ParseURI(uri)
+		if err != nil {
+			return
+		}
+		if !key.Validate() {
+			t.Fatalf("Failure: %q parsed to an invalid key %v", uri, key)
+		}
+		if _, err := key.Generate(); err != nil {
+			t.Fatalf("Failure: %q parsed to a key that fails to generate: %v", uri, err)
+		}
+	})
+}
@@ -0,0 +1,336 @@
+package otp
+
+import (
+	"context"
+	"crypto/subtle"
+	"math"
+	"strings"
+	"time"
+)
+
+// Reports whether the OTPs a and b are equal, in constant time. Comparing with
+// == returns as soon as a byte differs, so the time taken reveals how much of a
+// guess is correct, letting an attacker recover a valid OTP a digit at a time.
+// Inputs of different lengths take as long to compare as inputs of a's length.
+func Equal(a, b string) bool {
+	x, y := []byte(a), []byte(b)
+	lenEq := subtle.ConstantTimeEq(int32(len(x)), int32(len(y)))
+	if lenEq == 0 {
+		// Compare x with itself, to do the same work as for a content mismatch.
+		y = x
+	}
+	return subtle.ConstantTimeCompare(x, y)&lenEq == 1
+}
+
+// Removes whitespace surrounding a submitted code, as from pasting, before it
+// is verified. Whitespace within the code, as in "123 456", is kept, so such a
+// code is rejected: stripping it would accept input that is not the OTP, and
+// digit grouping is for display, not entry.
+func trimCode(code string) string {
+	return strings.TrimSpace(code)
+}
+
+// Verifies code against the TOTP parameter-set. Codes from up to skew
+// time-steps before or after the current one are accepted, to tolerate clock
+// drift between client and server; skew is clamped to MaxSkew. The comparison
+// is constant-time. Whitespace surrounding code is ignored, but whitespace
+// within it is not, so a code grouped for display, such as "123 456", is
+// rejected. If the receiver TOTPKey or code is malformed, false is returned.
+func (k *TOTPKey) Verify(code string, skew uint) bool {
+	return k.VerifyAt(code, time.Now(), skew)
+}
+
+// Reports whether code is the OTP of the current time-step, as Verify does
+// with a skew of 0. The comparison is constant-time. If the receiver TOTPKey
+// or code is malformed, false is returned.
+func (k *TOTPKey) Matches(code string) bool {
+	return k.VerifyAt(code, time.Now(), 0)
+}
+
+// Like Verify, but around time t rather than the current time, such as an
+// authoritative time from NTP, or a fixed one in tests.
+func (k *TOTPKey) VerifyAt(code string, t time.Time, skew uint) bool {
+	ok, _ := k.verifyAt(code, t, skew)
+	return ok
+}
+
+// Like Verify, but also returns the signed offset of the time-step that
+// matched: 0 for the current one, -1 for the previous one, 1 for the next one,
+// and so on. This can be used to monitor clock drift. If nothing matches,
+// (false, 0) is returned.
+func (k *TOTPKey) VerifyOffset(code string, skew uint) (bool, int) {
+	return k.verifyAt(code, time.Now(), skew)
+}
+
+// Like Verify, but also returns the counter of the time-step that matched, as
+// returned by CounterAt, rather than its offset, so that callers can implement
+// their own replay protection: by storing the last step used, and rejecting
+// codes of that step or earlier on the next verification. If nothing matches,
+// (false, 0) is returned.
+func (k *TOTPKey) VerifyStep(code string, skew uint) (matched bool, step uint64) {
+	return k.verifyStepAt(code, time.Now(), skew)
+}
+
+func (k *TOTPKey) verifyStepAt(code string, t time.Time, skew uint) (bool, uint64) {
+	ok, offset := k.verifyAt(code, t, skew)
+	if !ok {
+		return false, 0
+	}
+	// The counter cannot fail for a key that matched at t.
+	ctr, _ := k.CounterAt(t)
+	return true, uint64(int64(ctr) + int64(offset))
+}
+
+// Like Verify, but for several candidate codes, such as from a client that
+// submits more than one: it reports whether any of them matches, and returns
+// the first that does, so that callers can log which was accepted. If none
+// matches, (false, "") is returned.
+func (k *TOTPKey) VerifyAny(codes []string, skew uint) (bool, string) {
+	return k.verifyAnyAt(codes, time.Now(), skew)
+}
+
+func (k *TOTPKey) verifyAnyAt(codes []string, t time.Time, skew uint) (bool, string) {
+	for _, code := range codes {
+		if ok, _ := k.verifyAt(code, t, skew); ok {
+			return true, code
+		}
+	}
+	return false, ""
+}
+
+// Like Verify, but takes Digits to be the length of code, so that both 6- and
+// 8-digit codes verify while tokens are migrated from one length to the
+// other. Codes shorter than DefaultDigits or longer than MaxDigits are
+// rejected. Shorter codes are easier to guess, so a key verified this way is
+// only as strong as the shortest length accepted: once the migration is done,
+// switch back to Verify.
+func (k *TOTPKey) VerifyFlexibleDigits(code string, skew uint) bool {
+	return k.verifyFlexibleDigitsAt(code, time.Now(), skew)
+}
+
+func (k *TOTPKey) verifyFlexibleDigitsAt(code string, t time.Time, skew uint) bool {
+	code = trimCode(code)
+	if len(code) < DefaultDigits || len(code) > MaxDigits {
+		return false
+	}
+	c := *k
+	c.Digits = byte(len(code))
+	return c.VerifyAt(code, t, skew)
+}
+
+// The outcome of Classify.
+type Result byte
+
+const (
+	// The code matches no time-step within the skew, or is malformed.
+	Invalid Result = iota
+	// The code matches the current time-step.
+	Valid
+	// The code matches a previous time-step within the skew: it has expired.
+	ValidPrevious
+	// The code matches a following time-step within the skew, as from a fast
+	// clock.
+	ValidNext
+)
+
+// Like Verify, but distinguishes a code of the current time-step from one of a
+// previous or following time-step within the skew, so that, for example, a
+// code that has just expired can be reported as such.
+func (k *TOTPKey) Classify(code string, skew uint) Result {
+	return k.classifyAt(code, time.Now(), skew)
+}
+
+func (k *TOTPKey) classifyAt(code string, t time.Time, skew uint) Result {
+	ok, offset := k.verifyAt(code, t, skew)
+	switch {
+	case !ok:
+		return Invalid
+	case offset < 0:
+		return ValidPrevious
+	case offset > 0:
+		return ValidNext
+	default:
+		return Valid
+	}
+}
+
+// The widest search of EstimateDrift, in time-steps either side of the current
+// one: a day's worth, at the default time-step.
+const MaxDriftSkew = 2880
+
+// Searches up to maxSkew time-steps either side of the current one for code,
+// and returns the signed offset of the time-step that matched, as VerifyOffset
+// does, for tracking a token's clock drift over time. The search is clamped to
+// MaxDriftSkew time-steps. A wide search makes a match by chance likely, so
+// this is for diagnostics, and not for authentication.
+func (k *TOTPKey) EstimateDrift(code string, maxSkew uint) (offset int, ok bool) {
+	return k.estimateDriftAt(code, time.Now(), maxSkew)
+}
+
+func (k *TOTPKey) estimateDriftAt(code string, t time.Time, maxSkew uint) (int, bool) {
+	if maxSkew > MaxDriftSkew {
+		maxSkew = MaxDriftSkew
+	}
+	ok, offset := k.verifyAt(code, t, maxSkew)
+	return offset, ok
+}
+
+// Searches the time-steps around time t for code, closest first, preferring
+// the past over the future at equal distances.
+func (k *TOTPKey) verifyAt(code string, t time.Time, skew uint) (bool, int) {
+	ok, offset, _ := k.verifyAtContext(context.Background(), code, t, skew)
+	return ok, offset
+}
+
+// Like Verify, but stops early, returning ctx.Err(), if ctx is done before the
+// search of the time-steps completes. The search itself is in memory and
+// cheap; this is for callers that combine verification with network-backed
+// checks, such as a ReplayGuard or rate limiter, under a single deadline.
+func (k *TOTPKey) VerifyContext(ctx context.Context, code string,
+	skew uint) (bool, error) {
+	ok, _, err := k.verifyAtContext(ctx, code, time.Now(), skew)
+	return ok, err
+}
+
+func (k *TOTPKey) verifyAtContext(ctx context.Context, code string,
+	t time.Time, skew uint) (bool, int, error) {
+	if err := ctx.Err(); err != nil {
+		return false, 0, err
+	}
+	if !k.Validate() {
+		return false, 0, nil
+	}
+	code = trimCode(code)
+	h, err := k.conv(t)
+	if err != nil || len(code) != int(h.Digits) {
+		return false, 0, nil
+	}
+	g, err := h.generator()
+	if err != nil {
+		return false, 0, nil
+	}
+	ctr := h.Counter
+	try := func(c uint64) (bool, error) {
+		otp, err := g.generate(c)
+		return err == nil && Equal(otp, code), err
+	}
+	for d := uint64(0); d <= clampSkew(skew); d++ {
+		if err := ctx.Err(); err != nil {
+			return false, 0, err
+		}
+		if d > 0 && d <= ctr {
+			if ok, err := try(ctr - d); err != nil {
+				return false, 0, nil
+			} else if ok {
+				return true, -int(d), nil
+			}
+		}
+		if d <= math.MaxUint64-ctr {
+			if ok, err := try(ctr + d); err != nil {
+				return false, 0, nil
+			} else if ok {
+				return true, int(d), nil
+			}
+		}
+	}
+	return false, 0, nil
+}
+
+// The widest skew of Verify and its variants, in time-steps either side of
+// the current one, the same as MaxDriftSkew. Wider skews are clamped to it.
+const MaxSkew = MaxDriftSkew
+
+func clampSkew(skew uint) uint64 {
+	if skew > MaxSkew {
+		return MaxSkew
+	}
+	return uint64(skew)
+}
+
+// Verifies code against the HOTP parameter-set, trying the current counter and
+// up to lookAhead subsequent ones, as described in RFC 4226 section 7.2. On a
+// match, the counter following the matched one is returned so the caller can
+// persist the resynchronized counter; otherwise, the current counter is
+// returned. The receiver HOTPKey is not modified. The comparison is
+// constant-time. Whitespace surrounding code is ignored, as for
+// TOTPKey.Verify. If the receiver HOTPKey or code is malformed, false is
+// returned.
+func (k *HOTPKey) Verify(code string, lookAhead uint) (bool, uint64) {
+	return k.VerifyDirection(code, lookAhead, Increment)
+}
+
+// Like Verify, but the counter moves in direction dir. For Decrement, the
+// counters preceding the current one are tried, and the counter preceding the
+// matched one is returned. Counter 0, which has no predecessor to resume from,
+// is never tried when decrementing, nor math.MaxUint64 when incrementing; use
+// VerifyDirectionDetailed to tell a counter that ran out from a wrong code.
+func (k *HOTPKey) VerifyDirection(code string, lookAhead uint,
+	dir Direction) (bool, uint64) {
+	ok, ctr, _ := k.VerifyDirectionDetailed(code, lookAhead, dir)
+	return ok, ctr
+}
+
+// Like VerifyDirection, but also returns an error describing why code could
+// not match: ErrCounterUnderflow or ErrCounterOverflow if the search reached
+// the end of the counter's range without a match, so that the token must be
+// reprovisioned, ErrUnknownDirection for a dir other than Increment or
+// Decrement, or the error of ValidateDetailed for an invalid receiver
+// HOTPKey. A code that simply does not match, or is malformed, yields a nil
+// error.
+func (k *HOTPKey) VerifyDirectionDetailed(code string, lookAhead uint,
+	dir Direction) (bool, uint64, error) {
+	if dir != Increment && dir != Decrement {
+		return false, k.Counter, ErrUnknownDirection
+	}
+	if err := k.ValidateDetailed(); err != nil {
+		return false, k.Counter, err
+	}
+	code = trimCode(code)
+	if len(code) != int(k.Digits) {
+		return false, k.Counter, nil
+	}
+	g, err := k.generator()
+	if err != nil {
+		return false, k.Counter, err
+	}
+	for i := uint64(0); i <= uint64(lookAhead); i++ {
+		// A match on the last counter would leave no counter to resume from.
+		if dir == Increment && k.Counter > math.MaxUint64-1-i {
+			return false, k.Counter, ErrCounterOverflow
+		}
+		if dir == Decrement && k.Counter < 1+i {
+			return false, k.Counter, ErrCounterUnderflow
+		}
+		ctr := k.Counter + i
+		if dir == Decrement {
+			ctr = k.Counter - i
+		}
+		otp, err := g.generate(ctr)
+		if err != nil {
+			return false, k.Counter, err
+		}
+		if Equal(otp, code) {
+			if dir == Increment {
+				return true, ctr + 1, nil
+			}
+			return true, ctr - 1, nil
+		}
+	}
+	return false, k.Counter, nil
+}
+
+// Like Verify, but on a match, the receiver HOTPKey's counter is set to the
+// one following the matched counter, so that the key can be persisted as is.
+// Unlike Verify, this mutates the receiver. On a mismatch, false is returned
+// and the counter is left unchanged. If the receiver HOTPKey is invalid, the
+// error of ValidateDetailed is returned.
+func (k *HOTPKey) VerifyAndResync(code string, lookAhead uint) (bool, error) {
+	if err := k.ValidateDetailed(); err != nil {
+		return false, err
+	}
+	ok, ctr := k.Verify(code, lookAhead)
+	if ok {
+		k.Counter = ctr
+	}
+	return ok, nil
+}
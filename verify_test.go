@@ -0,0 +1,449 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTOTPVerify(t *testing.T) {
+	k := TOTPKey{
+		SecretKey:    "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		HashFunction: SHA1,
+		Digits:       8,
+		// A long time-step keeps the test clear of step boundaries.
+		TimeStep: 1 << 24,
+	}
+	code := k.OTP()
+	if !k.Verify(code, 1) {
+		t.Errorf("Failure: current code %s rejected", code)
+	}
+
+	h, _ := k.conv(time.Now())
+	ctr := h.Counter
+	for _, d := range []int64{-1, 1} {
+		h.Counter = uint64(int64(ctr) + d)
+		if !k.Verify(h.OTP(), 1) {
+			t.Errorf("Failure: code at offset %d rejected with skew 1", d)
+		}
+	}
+	for _, d := range []int64{-2, 2} {
+		h.Counter = uint64(int64(ctr) + d)
+		if k.Verify(h.OTP(), 1) {
+			t.Errorf("Failure: code at offset %d accepted with skew 1", d)
+		}
+	}
+
+	malformed := []string{"", "1234567", "123456789", "abcdefgh"}
+	for _, v := range malformed {
+		if k.Verify(v, 1) {
+			t.Errorf("Failure: malformed code %q accepted", v)
+		}
+	}
+
+	invalid := k
+	invalid.TimeStep = 0
+	if invalid.Verify(code, 1) {
+		t.Errorf("Failure: code accepted by invalid key %+v", invalid)
+	}
+}
+
+func TestTOTPVerifyAt(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	// The RFC 6238 appendix B values, for times 1111111109 and 1111111111,
+	// which are adjacent time-steps.
+	at := time.Unix(1111111109, 0)
+	if !k.VerifyAt("07081804", at, 0) {
+		t.Errorf("Failure: code at time 1111111109 rejected")
+	}
+	if k.VerifyAt("14050471", at, 0) {
+		t.Errorf("Failure: code of the next time-step accepted without skew")
+	}
+	if !k.VerifyAt("14050471", at, 1) {
+		t.Errorf("Failure: code of the next time-step rejected with skew 1")
+	}
+	if k.VerifyAt("07081804", at.Add(time.Hour), 1) {
+		t.Errorf("Failure: code accepted an hour later")
+	}
+	if k.VerifyAt("07081804", time.Unix(-1, 0), 1) {
+		t.Errorf("Failure: code accepted before the Unix epoch")
+	}
+
+	// Wide skews are clamped, rather than overflowing or searching for ever.
+	if !k.VerifyAt("07081804", at, math.MaxUint) || !k.VerifyAt("07081804", at, 1<<63) {
+		t.Errorf("Failure: current code rejected with the widest skew")
+	}
+	edge := k.OTPAt(at.Add(MaxSkew * 30 * time.Second))
+	if ok, off := k.verifyAt(edge, at, math.MaxUint); !ok || off != MaxSkew {
+		t.Errorf("Failure: code at MaxSkew: got (%v, %d), want (true, %d)", ok, off, MaxSkew)
+	}
+	if k.VerifyAt(k.OTPAt(at.Add(-(MaxSkew+1)*30*time.Second)), at, math.MaxUint) {
+		t.Errorf("Failure: code beyond MaxSkew accepted")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 3600}
+	// The time-step is an hour, so the code is unlikely to change mid-test.
+	code, expires := k.OTPWithExpiry()
+	if time.Until(expires) > time.Second && !k.Matches(code) {
+		t.Errorf("Failure: current code %s rejected", code)
+	}
+	prev := k.OTPAt(time.Now().Add(-time.Hour))
+	if prev != code && k.Matches(prev) {
+		t.Errorf("Failure: previous code %s accepted", prev)
+	}
+	for _, c := range []string{"", "0", "not a code", code + "0"} {
+		if k.Matches(c) {
+			t.Errorf("Failure: malformed code %q accepted", c)
+		}
+	}
+	bad := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1}
+	if bad.Matches("") || bad.Matches("000000") {
+		t.Errorf("Failure: code accepted by an invalid key")
+	}
+}
+
+func TestTOTPVerifyOffset(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	at := time.Unix(1111111109, 0)
+	for _, d := range []int{-3, -2, -1, 0, 1, 2, 3} {
+		code := k.OTPAt(at.Add(time.Duration(d) * 30 * time.Second))
+		ok, off := k.verifyAt(code, at, 3)
+		if !ok || off != d {
+			t.Errorf("Failure: code at offset %d: got (%v, %d)", d, ok, off)
+		}
+		if ok, off := k.verifyAt(code, at, 2); (d == -3 || d == 3) && (ok || off != 0) {
+			t.Errorf("Failure: code at offset %d with skew 2: got (%v, %d), want (false, 0)", d, ok, off)
+		}
+	}
+	if ok, off := k.VerifyOffset(k.OTP(), 1); !ok || off < -1 || off > 0 {
+		t.Errorf("Failure: current code: got (%v, %d)", ok, off)
+	}
+	if ok, off := k.VerifyOffset("", 1); ok || off != 0 {
+		t.Errorf("Failure: empty code: got (%v, %d), want (false, 0)", ok, off)
+	}
+}
+
+func TestVerifyStep(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	// The RFC 6238 appendix B time 1111111109 is in step 0x23523EC.
+	at := time.Unix(1111111109, 0)
+	for _, d := range []int{-2, -1, 0, 1, 2} {
+		code := k.OTPAt(at.Add(time.Duration(d) * 30 * time.Second))
+		want := uint64(0x23523EC + d)
+		if ok, step := k.verifyStepAt(code, at, 2); !ok || step != want {
+			t.Errorf("Failure: code at offset %d: got (%v, %d), want (true, %d)", d, ok, step, want)
+		}
+	}
+	if ok, step := k.verifyStepAt("07081804", at, 0); !ok || step != 0x23523EC {
+		t.Errorf("Failure: RFC 6238 code: got (%v, %d), want (true, %d)", ok, step, 0x23523EC)
+	}
+	if ok, step := k.verifyStepAt(k.OTPAt(at.Add(90*time.Second)), at, 2); ok || step != 0 {
+		t.Errorf("Failure: code beyond skew: got (%v, %d), want (false, 0)", ok, step)
+	}
+
+	// With T0, steps are counted from it, and the first step is 0.
+	k.T0 = 1111111080
+	if ok, step := k.verifyStepAt(k.OTPAt(time.Unix(1111111080, 0)), at, 1); !ok || step != 0 {
+		t.Errorf("Failure: code of the first step: got (%v, %d), want (true, 0)", ok, step)
+	}
+
+	k.T0 = 0
+	if ok, step := k.VerifyStep(k.OTP(), 1); !ok || step == 0 {
+		t.Errorf("Failure: current code: got (%v, %d)", ok, step)
+	}
+}
+
+func TestVerifyAny(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	at := time.Unix(1111111109, 0)
+	w := []struct {
+		codes  []string
+		skew   uint
+		ok     bool
+		expect string
+	}{
+		{[]string{"07081804"}, 0, true, "07081804"},
+		{[]string{"00000000", "", "14050471"}, 1, true, "14050471"},
+		{[]string{"00000000", "14050471"}, 0, false, ""},
+		{[]string{"14050471", "07081804"}, 1, true, "14050471"},
+		{nil, 1, false, ""},
+	}
+	for _, v := range w {
+		if ok, code := k.verifyAnyAt(v.codes, at, v.skew); ok != v.ok || code != v.expect {
+			t.Errorf("Failure: codes %q with skew %d: got (%v, %q), want (%v, %q)", v.codes, v.skew, ok, code, v.ok, v.expect)
+		}
+	}
+	if ok, code := k.VerifyAny([]string{"", k.OTP()}, 1); !ok || code == "" {
+		t.Errorf("Failure: current code: got (%v, %q)", ok, code)
+	}
+}
+
+func TestVerifyFlexibleDigits(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	// The RFC 6238 appendix B value for time 1111111109, and its last 6, 7,
+	// 4, and 5 digits.
+	at := time.Unix(1111111109, 0)
+	w := []struct {
+		code   string
+		expect bool
+	}{
+		{"07081804", true},
+		{"081804", true},
+		{"7081804", true},
+		{"07081805", false},
+		{"1804", false},
+		{"81804", false},
+		{"", false},
+		{"0007081804", false},
+		{"00000007081804", false},
+	}
+	for _, v := range w {
+		if got := k.verifyFlexibleDigitsAt(v.code, at, 0); got != v.expect {
+			t.Errorf("Mismatch on code %q:\nWant: %v Got: %v", v.code, v.expect, got)
+		}
+	}
+	if k.Digits != 6 {
+		t.Errorf("Failure: Digits modified to %d", k.Digits)
+	}
+	if k.VerifyAt("07081804", at, 0) {
+		t.Errorf("Failure: 8-digit code accepted by Verify")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	at := time.Unix(1111111109, 0)
+	w := []struct {
+		offset int
+		skew   uint
+		expect Result
+	}{
+		{0, 0, Valid},
+		{0, 1, Valid},
+		{-1, 1, ValidPrevious},
+		{-2, 2, ValidPrevious},
+		{1, 1, ValidNext},
+		{2, 2, ValidNext},
+		{-1, 0, Invalid},
+		{3, 2, Invalid},
+	}
+	for _, v := range w {
+		code := k.OTPAt(at.Add(time.Duration(v.offset) * 30 * time.Second))
+		if got := k.classifyAt(code, at, v.skew); got != v.expect {
+			t.Errorf("Mismatch on offset %d with skew %d:\nWant: %d Got: %d", v.offset, v.skew, v.expect, got)
+		}
+	}
+	if got := k.classifyAt("", at, 1); got != Invalid {
+		t.Errorf("Mismatch on empty code:\nWant: %d Got: %d", Invalid, got)
+	}
+	if got := k.Classify(k.OTP(), 1); got != Valid && got != ValidPrevious {
+		t.Errorf("Failure: current code classified as %d", got)
+	}
+}
+
+func TestVerifyContext(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	at := time.Unix(1111111109, 0)
+	ctx := context.Background()
+	if ok, off, err := k.verifyAtContext(ctx, "14050471", at, 1); !ok || off != 1 || err != nil {
+		t.Errorf("Failure: got (%v, %d, %v), want (true, 1, nil)", ok, off, err)
+	}
+	if ok, _, err := k.verifyAtContext(ctx, "00000000", at, 1); ok || err != nil {
+		t.Errorf("Failure: got (%v, %v), want (false, nil)", ok, err)
+	}
+	if ok, err := k.VerifyContext(ctx, k.OTP(), 1); !ok || err != nil {
+		t.Errorf("Failure: current code: got (%v, %v)", ok, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if ok, err := k.VerifyContext(cancelled, k.OTP(), 1); ok || err != context.Canceled {
+		t.Errorf("Failure: cancelled: got (%v, %v), want (false, %v)", ok, err, context.Canceled)
+	}
+
+	// Cancellation is noticed between time-steps of the search.
+	c := &countdownContext{Context: ctx, n: 3}
+	if ok, _, err := k.verifyAtContext(c, "00000000", at, MaxDriftSkew); ok || err != context.Canceled {
+		t.Errorf("Failure: cancelled mid-search: got (%v, %v), want (false, %v)", ok, err, context.Canceled)
+	}
+}
+
+// Reports cancellation once Err has been called n times.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n--; c.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestEstimateDrift(t *testing.T) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 8, TimeStep: 30}
+	at := time.Unix(1111111109, 0)
+	for _, d := range []int{-100, -1, 0, 7, 2880} {
+		code := k.OTPAt(at.Add(time.Duration(d) * 30 * time.Second))
+		if off, ok := k.estimateDriftAt(code, at, 3000); !ok || off != d {
+			t.Errorf("Failure: code at offset %d: got (%d, %v)", d, off, ok)
+		}
+	}
+	// The search is clamped to MaxDriftSkew.
+	code := k.OTPAt(at.Add((MaxDriftSkew + 1) * 30 * time.Second))
+	if off, ok := k.estimateDriftAt(code, at, MaxDriftSkew+10); ok {
+		t.Errorf("Failure: code beyond MaxDriftSkew matched at offset %d", off)
+	}
+	if off, ok := k.EstimateDrift(k.OTP(), 1); !ok || off < -1 || off > 0 {
+		t.Errorf("Failure: current code: got (%d, %v)", off, ok)
+	}
+}
+
+func TestHOTPVerify(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 0}
+	// RFC 4226 appendix D values for counters 0 through 9.
+	want := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+
+	for i, v := range want[:4] {
+		ok, ctr := k.Verify(v, 3)
+		if !ok || ctr != uint64(i)+1 {
+			t.Errorf("Failure: code %s: got (%v, %d), want (true, %d)", v, ok, ctr, i+1)
+		}
+	}
+	if ok, ctr := k.Verify(want[4], 3); ok || ctr != 0 {
+		t.Errorf("Failure: code beyond look-ahead window: got (%v, %d), want (false, 0)", ok, ctr)
+	}
+	if k.Counter != 0 {
+		t.Errorf("Failure: Verify mutated the receiver's counter to %d", k.Counter)
+	}
+
+	k.Counter = 5
+	if ok, _ := k.Verify(want[4], 9); ok {
+		t.Errorf("Failure: code for a past counter accepted")
+	}
+	if ok, ctr := k.Verify(want[9], 9); !ok || ctr != 10 {
+		t.Errorf("Failure: code %s: got (%v, %d), want (true, 10)", want[9], ok, ctr)
+	}
+
+	for _, v := range []string{"", "28708", "2870822", "abcdef"} {
+		if ok, _ := k.Verify(v, 9); ok {
+			t.Errorf("Failure: malformed code %q accepted", v)
+		}
+	}
+}
+
+func TestVerifyWhitespace(t *testing.T) {
+	h := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6}
+	tk := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	// The RFC 4226 appendix D value for counter 0, and the 6-digit value for
+	// time 59 of RFC 6238 appendix B.
+	at := time.Unix(59, 0)
+	w := []struct {
+		hotp, totp string
+		want       bool
+	}{
+		{" 755224 ", " 287082 ", true},
+		{"\t755224\r\n", "\t287082\r\n", true},
+		// Grouping spaces are part of the code, and are not removed.
+		{"755 224", "287 082", false},
+		{" 75522 ", " 28708 ", false},
+		{"", "", false},
+		{"      ", "      ", false},
+	}
+	for _, v := range w {
+		if ok, _ := h.Verify(v.hotp, 0); ok != v.want {
+			t.Errorf("Mismatch on HOTP code %q\nWant: %v Got: %v", v.hotp, v.want, ok)
+		}
+		if ok := tk.VerifyAt(v.totp, at, 0); ok != v.want {
+			t.Errorf("Mismatch on TOTP code %q\nWant: %v Got: %v", v.totp, v.want, ok)
+		}
+	}
+
+	// A padded code is the same code to a ReplayGuard.
+	g := NewMemoryReplayGuard()
+	code := tk.OTP()
+	if !tk.VerifyWithGuard("alice", code, 1, g) {
+		t.Fatalf("Failure: code %s rejected", code)
+	}
+	if tk.VerifyWithGuard("alice", " "+code+" ", 1, g) {
+		t.Errorf("Failure: padded replay of %s accepted", code)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	w := []struct {
+		a, b   string
+		expect bool
+	}{
+		{"", "", true},
+		{"123456", "123456", true},
+		{"123456", "123457", false},
+		{"123456", "023456", false},
+		{"123456", "12345", false},
+		{"12345", "123456", false},
+		{"123456", "", false},
+		{"", "123456", false},
+	}
+	for _, v := range w {
+		if got := Equal(v.a, v.b); got != v.expect {
+			t.Errorf("Mismatch on Equal(%q, %q):\nWant: %v Got: %v", v.a, v.b, v.expect, got)
+		}
+	}
+}
+
+func TestVerifyAndResync(t *testing.T) {
+	k := HOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, Counter: 2}
+	// RFC 4226 appendix D values for counters 0 through 9.
+	want := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+
+	if ok, err := k.VerifyAndResync(want[4], 3); !ok || err != nil || k.Counter != 5 {
+		t.Errorf("Failure: counter 4: got (%v, %v) and counter %d, want counter 5", ok, err, k.Counter)
+	}
+	// The counter moved past the code, which cannot be used again.
+	if ok, err := k.VerifyAndResync(want[4], 3); ok || err != nil || k.Counter != 5 {
+		t.Errorf("Failure: replayed code: got (%v, %v) and counter %d", ok, err, k.Counter)
+	}
+	if ok, _ := k.VerifyAndResync(want[9], 3); ok || k.Counter != 5 {
+		t.Errorf("Failure: code beyond look-ahead window: got %v and counter %d", ok, k.Counter)
+	}
+	if ok, _ := k.VerifyAndResync(want[5], 0); !ok || k.Counter != 6 {
+		t.Errorf("Failure: counter 5: got %v and counter %d, want counter 6", ok, k.Counter)
+	}
+
+	bad := HOTPKey{SecretKey: "NOTBASE32!", HashFunction: SHA1, Digits: 6}
+	if ok, err := bad.VerifyAndResync("123456", 3); ok || !errors.Is(err, ErrBadBase32) {
+		t.Errorf("Failure: invalid key: got (%v, %v), want %v", ok, err, ErrBadBase32)
+	}
+}
+
+func BenchmarkTOTPVerify(b *testing.B) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	now := time.Unix(1111111111, 0)
+	for i := 0; i < b.N; i++ {
+		// "000000" matches none of the 21 time-steps, so all are computed.
+		k.verifyAt("000000", now, 10)
+	}
+}
+
+// The search of BenchmarkTOTPVerify, constructing a key, and so an HMAC, per
+// time-step, for comparison.
+func BenchmarkTOTPVerifyNaive(b *testing.B) {
+	k := TOTPKey{SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", HashFunction: SHA1, Digits: 6, TimeStep: 30}
+	now := time.Unix(1111111111, 0)
+	for i := 0; i < b.N; i++ {
+		h, _ := k.conv(now)
+		ctr := h.Counter
+		for c := ctr - 10; c <= ctr+10; c++ {
+			h.Counter = c
+			otp, _ := h.Generate()
+			Equal(otp, "000000")
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The secret-key length of YubiKeys in OATH-HOTP mode.
+const yubicoSecretSize = 20
+
+var (
+	ErrYubicoDigits    = errors.New("otp: Yubico OATH-HOTP keys have 6 or 8 digits")
+	ErrYubicoSecretLen = errors.New("otp: Yubico OATH-HOTP secret-keys are 20 bytes")
+)
+
+// Returns an HOTPKey for a YubiKey slot in OATH-HOTP mode. Such slots use SHA1,
+// 6 or 8 digits, and a counter, or moving factor, starting at 0. The secret,
+// base-32 encoded, must be exactly 20 bytes: the YubiKey personalization tools
+// accept no other length, and a key configured with a different one would
+// never match the token.
+func YubicoHOTP(secret string, digits byte) (*HOTPKey, error) {
+	if digits != 6 && digits != 8 {
+		return nil, ErrYubicoDigits
+	}
+	sk, err := decodeSecret(secret, EncodingStd)
+	if err != nil {
+		return nil, err
+	}
+	if len(sk) != yubicoSecretSize {
+		return nil, fmt.Errorf("%w, not %d", ErrYubicoSecretLen, len(sk))
+	}
+	return &HOTPKey{
+		SecretKey:    secret,
+		HashFunction: SHA1,
+		Digits:       digits,
+	}, nil
+}
@@ -0,0 +1,41 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestYubicoHOTP(t *testing.T) {
+	const sk = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for _, d := range []byte{6, 8} {
+		k, err := YubicoHOTP(sk, d)
+		if err != nil {
+			t.Fatalf("Failure: %d digits: %v", d, err)
+		}
+		want := HOTPKey{SecretKey: sk, HashFunction: SHA1, Digits: d}
+		if k.StringWithSecret() != want.StringWithSecret() {
+			t.Errorf("Mismatch on %d digits:\nWant: %s Got: %s", d, want.StringWithSecret(), k.StringWithSecret())
+		}
+		if !k.Validate() {
+			t.Errorf("Failure: %d digits: key is invalid", d)
+		}
+	}
+	if k, _ := YubicoHOTP(sk, 6); k.OTP() != "755224" {
+		t.Errorf("Mismatch on RFC 4226 counter 0\nWant: 755224 Got: %s", k.OTP())
+	}
+
+	for _, d := range []byte{0, 5, 7, 10} {
+		if _, err := YubicoHOTP(sk, d); err != ErrYubicoDigits {
+			t.Errorf("Failure: %d digits: got error %v, want %v", d, err, ErrYubicoDigits)
+		}
+	}
+	// 16 and 32 bytes would be valid HOTP secret-keys, but not Yubico ones.
+	for _, s := range []string{"GEZDGNBVGY3TQOJQGEZDGNBVGY", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA"} {
+		if _, err := YubicoHOTP(s, 6); !errors.Is(err, ErrYubicoSecretLen) {
+			t.Errorf("Failure: secret %q: got error %v, want %v", s, err, ErrYubicoSecretLen)
+		}
+	}
+	if _, err := YubicoHOTP("NOTBASE32!", 6); !errors.Is(err, ErrBadBase32) {
+		t.Errorf("Failure: invalid secret: got error %v, want %v", err, ErrBadBase32)
+	}
+}